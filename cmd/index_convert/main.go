@@ -0,0 +1,86 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/bitterfly/search/diskindex"
+	"github.com/bitterfly/search/indices"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "index_convert"
+	app.Usage = "Converts a gob index into the memory mapped format and back"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "input, i",
+			Usage: "File with index",
+			Value: "/tmp/index.gob.gz",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "File to write the converted index to",
+			Value: "/tmp/index.idx",
+		},
+		cli.BoolFlag{
+			Name:  "reverse, r",
+			Usage: "Convert a memory mapped index back into a gob one",
+		},
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	if c.Bool("reverse") {
+		toGob(c.String("input"), c.String("output"))
+	} else {
+		fromGob(c.String("input"), c.String("output"))
+	}
+}
+
+func fromGob(input, output string) {
+	ti := indices.NewTotalIndex()
+	err := ti.DeserialiseFromFile(input)
+	if err != nil {
+		log.Fatalf("Unable to read index: %s", err)
+	}
+
+	err = diskindex.WriteFile(output, ti)
+	if err != nil {
+		log.Fatalf("Unable to write index: %s", err)
+	}
+
+	log.Printf("Wrote %d documents and %d terms to %s", len(ti.Documents), ti.Dictionary.Size, output)
+}
+
+func toGob(input, output string) {
+	index, err := diskindex.Open(input)
+	if err != nil {
+		log.Fatalf("Unable to open index: %s", err)
+	}
+	defer index.Close()
+
+	ti, err := index.Load()
+	if err != nil {
+		log.Fatalf("Unable to read index: %s", err)
+	}
+
+	err = ti.Forward.Decompress()
+	if err == nil {
+		err = ti.Inverse.Decompress()
+	}
+	if err != nil {
+		log.Fatalf("Unable to decode index: %s", err)
+	}
+	ti.Normalise()
+
+	err = ti.SerialiseToFile(output)
+	if err != nil {
+		log.Fatalf("Unable to write index: %s", err)
+	}
+}
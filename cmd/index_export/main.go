@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/bitterfly/search/formats"
+	"github.com/bitterfly/search/indices"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "index_export"
+	app.Usage = "Exports an index as JSON Lines or as a sparse matrix with its vocabulary, classes and documents in TSV files"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "index, i",
+			Usage: "File with index",
+			Value: "/tmp/index.gob.gz",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "Prefix of the written files, the extension depends on the format",
+			Value: "/tmp/index",
+		},
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "jsonl, svmlight or mm (Matrix Market)",
+			Value: "jsonl",
+		},
+		cli.StringFlag{
+			Name:  "weighting, w",
+			Usage: "Values of the matrix, tf or tfidf",
+			Value: "tf",
+		},
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	weighting, err := formats.ParseWeighting(c.String("weighting"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ti := indices.NewTotalIndex()
+	err = ti.DeserialiseFromFile(c.String("index"))
+	if err != nil {
+		log.Fatalf("Unable to read index: %s", err)
+	}
+
+	output := c.String("output")
+	switch c.String("format") {
+	case "jsonl":
+		write(output+".jsonl", func(w io.Writer) error { return formats.WriteJSONLines(w, ti) })
+		return
+	case "svmlight":
+		write(output+".svm", func(w io.Writer) error { return formats.WriteSVMlight(w, ti, weighting) })
+	case "mm":
+		write(output+".mtx", func(w io.Writer) error { return formats.WriteMatrixMarket(w, ti, weighting) })
+		write(output+".documents.tsv", func(w io.Writer) error { return formats.WriteDocuments(w, ti) })
+	default:
+		log.Fatalf("Unknown format %s", c.String("format"))
+	}
+
+	write(output+".vocabulary.tsv", func(w io.Writer) error { return formats.WriteVocabulary(w, ti) })
+	write(output+".classes.tsv", func(w io.Writer) error { return formats.WriteClasses(w, ti) })
+}
+
+func write(filename string, writer func(io.Writer) error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		log.Fatalf("Unable to create %s: %s", filename, err)
+	}
+
+	err = writer(f)
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		log.Fatalf("Unable to write %s: %s", filename, err)
+	}
+
+	log.Printf("Wrote %s", filename)
+}
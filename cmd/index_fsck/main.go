@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/bitterfly/search/diskindex"
+	"github.com/bitterfly/search/indices"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "index_fsck"
+	app.Usage = "Checks the consistency of an index, either a gob or a memory mapped one, and lists the problems"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "index, i",
+			Usage: "File with index",
+			Value: "/tmp/index.gob.gz",
+		},
+		cli.IntFlag{
+			Name:  "max, m",
+			Usage: "Print at most this many problems, 0 for all of them",
+			Value: 100,
+		},
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	ti, err := load(c.String("index"))
+	if err != nil {
+		log.Fatalf("Unable to read index: %s", err)
+	}
+
+	fmt.Printf("%s: %d documents, %d terms\n", c.String("index"), len(ti.Documents), ti.Dictionary.Size)
+	fmt.Printf("metadata: %s\n", ti.Metadata)
+
+	errs := ti.Verify()
+	if len(errs) == 0 {
+		fmt.Printf("no problems found\n")
+		return
+	}
+
+	counts := make(map[string]int)
+	for i, err := range errs {
+		counts[kind(err)]++
+		if c.Int("max") == 0 || i < c.Int("max") {
+			fmt.Printf("%s\n", err)
+		}
+	}
+	if c.Int("max") != 0 && len(errs) > c.Int("max") {
+		fmt.Printf("... and %d more\n", len(errs)-c.Int("max"))
+	}
+
+	kinds := make([]string, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+
+	fmt.Printf("\n%d problems found:\n", len(errs))
+	for _, k := range kinds {
+		fmt.Printf("  %s: %d\n", k, counts[k])
+	}
+	os.Exit(1)
+}
+
+// Memory mapped indices are recognised by their header, anything else is read as a gob index
+func load(filename string) (*indices.TotalIndex, error) {
+	index, err := diskindex.Open(filename)
+	if err == nil {
+		defer index.Close()
+		return index.Load()
+	}
+
+	ti := indices.NewTotalIndex()
+	err = ti.DeserialiseFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ti, nil
+}
+
+func kind(err error) string {
+	switch err.(type) {
+	case *indices.ListError:
+		return "posting lists"
+	case *indices.PostingMismatchError:
+		return "forward and inverse mismatches"
+	case *indices.DocumentError:
+		return "documents"
+	case *indices.SizeError:
+		return "sizes"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"github.com/bitterfly/search/formats"
+	"github.com/bitterfly/search/indices"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "index_import"
+	app.Usage = "Builds an index from a sparse document x term matrix in the SVMlight or the Matrix Market format"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "matrix, m",
+			Usage: "File with the matrix, the values are term counts",
+		},
+		cli.StringFlag{
+			Name:  "format, f",
+			Usage: "svmlight or mm (Matrix Market)",
+			Value: "svmlight",
+		},
+		cli.StringFlag{
+			Name:  "vocabulary, v",
+			Usage: "TSV file with the id and the term in the first two columns, or a file with a term per line",
+		},
+		cli.StringFlag{
+			Name:  "classes, c",
+			Usage: "TSV file with the id and the name of every class, without it the SVMlight labels are the class names",
+		},
+		cli.StringFlag{
+			Name:  "documents, d",
+			Usage: "TSV file with the row, name and class ids of every document of a Matrix Market matrix",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "File to write the index to",
+			Value: "/tmp/index.gob.gz",
+		},
+		cli.BoolFlag{
+			Name:  "compress",
+			Usage: "Compress the posting lists",
+		},
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	if c.String("matrix") == "" || c.String("vocabulary") == "" {
+		log.Fatalf("A matrix and a vocabulary are required")
+	}
+
+	var vocabulary, classes []string
+	read(c.String("vocabulary"), func(r io.Reader) (err error) {
+		vocabulary, err = formats.ReadVocabulary(r)
+		return
+	})
+	if c.String("classes") != "" {
+		read(c.String("classes"), func(r io.Reader) (err error) {
+			classes, err = formats.ReadClasses(r)
+			return
+		})
+	}
+
+	var ti *indices.TotalIndex
+	switch c.String("format") {
+	case "svmlight":
+		read(c.String("matrix"), func(r io.Reader) (err error) {
+			ti, err = formats.ReadSVMlight(r, vocabulary, classes)
+			return
+		})
+	case "mm":
+		var documents io.Reader
+		if c.String("documents") != "" {
+			f, err := os.Open(c.String("documents"))
+			if err != nil {
+				log.Fatalf("Unable to open %s: %s", c.String("documents"), err)
+			}
+			defer f.Close()
+			documents = f
+		}
+
+		read(c.String("matrix"), func(r io.Reader) (err error) {
+			ti, err = formats.ReadMatrixMarket(r, vocabulary, documents, classes)
+			return
+		})
+	default:
+		log.Fatalf("Unknown format %s", c.String("format"))
+	}
+
+	// The tokeniser is left empty, queries aren't checked against imported indices
+	ti.Metadata.Source = c.String("matrix")
+
+	if c.Bool("compress") {
+		ti.Forward.Compress()
+		ti.Inverse.Compress()
+	}
+
+	err := ti.SerialiseToFile(c.String("output"))
+	if err != nil {
+		log.Fatalf("Unable to write index: %s", err)
+	}
+	log.Printf("Wrote %d documents and %d terms to %s", len(ti.Documents), ti.Dictionary.Size, c.String("output"))
+}
+
+func read(filename string, reader func(io.Reader) error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		log.Fatalf("Unable to open %s: %s", filename, err)
+	}
+	defer f.Close()
+
+	err = reader(f)
+	if err != nil {
+		log.Fatalf("Unable to read %s: %s", filename, err)
+	}
+}
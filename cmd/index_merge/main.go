@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/bitterfly/search/docstore"
+	"github.com/bitterfly/search/indices"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "index_merge"
+	app.Usage = "Merges indices built separately into one, the documents of each are put after the ones of the previous"
+	app.Flags = []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "input, i",
+			Usage: "File with index, given at least twice",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "File to write the merged index to",
+			Value: "/tmp/merged.gob.gz",
+		},
+		cli.StringSliceFlag{
+			Name:  "store",
+			Usage: "File with the stored documents of an index, given once for every index in the same order. If not specified, the stores aren't merged",
+		},
+		cli.StringFlag{
+			Name:  "output-store",
+			Usage: "File to write the merged document store to",
+			Value: "/tmp/merged_store.gob.gz",
+		},
+		cli.BoolFlag{
+			Name:  "compress",
+			Usage: "Compress the posting lists of the merged index",
+		},
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	inputs := c.StringSlice("input")
+	if len(inputs) < 2 {
+		log.Fatalf("At least two indices have to be given")
+	}
+
+	stores := c.StringSlice("store")
+	if len(stores) != 0 && len(stores) != len(inputs) {
+		log.Fatalf("%d document stores given for %d indices", len(stores), len(inputs))
+	}
+
+	var merged *indices.TotalIndex
+	var mergedStore *docstore.Store
+	if len(stores) != 0 {
+		mergedStore = docstore.New()
+	}
+
+	for i, input := range inputs {
+		ti := indices.NewTotalIndex()
+		err := ti.DeserialiseFromFile(input)
+		if err != nil {
+			log.Fatalf("Unable to read index %s: %s", input, err)
+		}
+		log.Printf("Loaded %s: %d documents and %d terms", input, len(ti.Documents), ti.Dictionary.Size)
+
+		if mergedStore != nil {
+			offset := 0
+			if merged != nil {
+				offset = len(merged.Documents)
+			}
+			appendStore(mergedStore, stores[i], offset)
+		}
+
+		if merged == nil {
+			merged = ti
+			continue
+		}
+
+		merged, err = indices.Merge(merged, ti)
+		if err != nil {
+			log.Fatalf("Unable to merge %s: %s", input, err)
+		}
+	}
+
+	merged.VerifyOrFatal()
+	if c.Bool("compress") {
+		merged.Forward.Compress()
+		merged.Inverse.Compress()
+	}
+
+	err := merged.SerialiseToFile(c.String("output"))
+	if err != nil {
+		log.Fatalf("Unable to write index: %s", err)
+	}
+	log.Printf("Wrote %d documents and %d terms to %s", len(merged.Documents), merged.Dictionary.Size, c.String("output"))
+
+	if mergedStore != nil {
+		err = mergedStore.SerialiseToFile(c.String("output-store"))
+		if err != nil {
+			log.Fatalf("Unable to write document store: %s", err)
+		}
+	}
+}
+
+// The documents of the store get ids starting from offset
+func appendStore(merged *docstore.Store, filename string, offset int) {
+	store := docstore.New()
+	err := store.DeserialiseFromFile(filename)
+	if err != nil {
+		log.Fatalf("Unable to read document store %s: %s", filename, err)
+	}
+
+	for len(merged.Records) < offset {
+		merged.Records = append(merged.Records, nil)
+	}
+	merged.Records = append(merged.Records, store.Records...)
+}
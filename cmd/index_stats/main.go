@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/stats"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "index_stats"
+	app.Usage = "Reports statistics about the collection in an index as a table and as JSON"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "index, i",
+			Usage: "File with index",
+			Value: "/tmp/index.gob.gz",
+		},
+		cli.IntFlag{
+			Name:  "top, n",
+			Usage: "Number of terms and classes to list",
+			Value: 20,
+		},
+		cli.StringFlag{
+			Name:  "json, j",
+			Usage: "File to write the statistics to as JSON, - to write them to stdout instead of the table",
+			Value: "",
+		},
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	ti := indices.NewTotalIndex()
+	err := ti.DeserialiseFromFile(c.String("index"))
+	if err != nil {
+		log.Fatalf("Unable to read index: %s", err)
+	}
+
+	s := stats.Compute(ti, c.Int("top"))
+
+	if c.String("json") != "-" {
+		err = s.WriteTable(os.Stdout, c.Int("top"))
+		if err != nil {
+			log.Fatalf("Unable to write table: %s", err)
+		}
+	}
+
+	if c.String("json") == "" {
+		return
+	}
+
+	encoded, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		log.Fatalf("Unable to encode statistics: %s", err)
+	}
+	encoded = append(encoded, '\n')
+
+	if c.String("json") == "-" {
+		_, err = os.Stdout.Write(encoded)
+	} else {
+		err = ioutil.WriteFile(c.String("json"), encoded, 0644)
+	}
+	if err != nil {
+		log.Fatalf("Unable to write statistics: %s", err)
+	}
+}
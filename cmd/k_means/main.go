@@ -35,6 +35,7 @@ func main() {
 			Usage: "Stopwords file. If not specified, defaults to ${xmldir}/stopwords",
 			Value: "",
 		},
+		processing.IgnoreMetadataFlag,
 	}
 
 	app.Action = mainCommand
@@ -60,6 +61,8 @@ func mainCommand(c *cli.Context) {
 		log.Fatal("unable to get stopwords: %s", err)
 	}
 
+	processing.CheckOrFatal(ti.Metadata, tokeniser, c.Bool(processing.IgnoreMetadataFlag.Name))
+
 	docInfo := processing.Count(documents[0], tokeniser)
 	i := kmeans.ClosestCentroidToInfo(ti, docInfo)
 
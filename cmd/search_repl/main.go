@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+	"github.com/bitterfly/search/query"
+	"github.com/bitterfly/search/ranking"
+
+	"github.com/urfave/cli"
+)
+
+const help = `Type a query to see the best ranked documents, or one of:
+  :boolean <query>   documents matching a boolean query (AND, OR, NOT, "phrase", NEAR/n, oil*, oil~1)
+  :mode <bm25|tfidf> ranking function for plain queries
+  :k <number>        number of ranked hits to show
+  :doc <id>          information and forward postings of a document
+  :df <word>         document and collection frequency of a word
+  :cluster <id>      cluster of a document and its most common classes
+  :help              this message
+  :quit              exit`
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "search_repl"
+	app.Usage = "Loads an index and runs queries typed on the standard input"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "index, i",
+			Usage: "File with index",
+			Value: "/tmp/kmeans.gob.gz",
+		},
+		cli.StringFlag{
+			Name:  "stopwords, s",
+			Usage: "Stopwords file, the same one the index was built with",
+			Value: "stopwords",
+		},
+		processing.IgnoreMetadataFlag,
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+type repl struct {
+	index     *indices.TotalIndex
+	tokeniser processing.Tokeniser
+	engine    *query.Engine
+	rankers   map[string]*ranking.Ranker
+	mode      string
+	k         int
+	out       io.Writer
+}
+
+func mainCommand(c *cli.Context) {
+	ti := indices.NewTotalIndex()
+	err := ti.DeserialiseFromFile(c.String("index"))
+	if err != nil {
+		log.Fatalf("unable to load index: %s", err)
+	}
+	ti.Finalise()
+
+	tokeniser, err := processing.NewEnglishTokeniserFromFile(c.String("stopwords"))
+	if err != nil {
+		log.Fatalf("unable to get stopwords: %s", err)
+	}
+
+	processing.CheckOrFatal(ti.Metadata, tokeniser, c.Bool(processing.IgnoreMetadataFlag.Name))
+
+	r := &repl{
+		index:     ti,
+		tokeniser: tokeniser,
+		engine:    query.NewEngine(ti, tokeniser),
+		rankers: map[string]*ranking.Ranker{
+			"bm25":  ranking.NewRanker(ti, tokeniser, ranking.NewBM25(ti, ranking.DefaultK1, ranking.DefaultB)),
+			"tfidf": ranking.NewRanker(ti, tokeniser, ranking.NewTFIDF(ti)),
+		},
+		mode: "bm25",
+		k:    10,
+		out:  os.Stdout,
+	}
+
+	fmt.Printf("loaded %d documents and %d terms, type :help for help\n", ti.Len(), len(ti.Inverse.PostingLists))
+	r.run(os.Stdin)
+}
+
+func (r *repl) run(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, ":") {
+			r.search(line)
+			continue
+		}
+
+		command, argument := line, ""
+		if space := strings.IndexByte(line, ' '); space != -1 {
+			command, argument = line[:space], strings.TrimSpace(line[space+1:])
+		}
+
+		switch command {
+		case ":boolean", ":b":
+			r.boolean(argument)
+		case ":mode":
+			r.setMode(argument)
+		case ":k":
+			r.setK(argument)
+		case ":doc", ":d":
+			r.document(argument)
+		case ":df":
+			r.frequency(argument)
+		case ":cluster", ":c":
+			r.cluster(argument)
+		case ":help", ":h":
+			fmt.Fprintln(r.out, help)
+		case ":quit", ":q":
+			return
+		default:
+			fmt.Fprintf(r.out, "unknown command %s, type :help for help\n", command)
+		}
+	}
+}
+
+func (r *repl) classNames(doc *indices.DocumentInfo) string {
+	names := make([]string, len(doc.Classes))
+	for i, class := range doc.Classes {
+		names[i] = string(r.index.ClassNames.GetInverse(class))
+	}
+	return strings.Join(names, ", ")
+}
+
+func (r *repl) suggest(text string) {
+	if corrected, ok := r.engine.DidYouMean(text, query.DefaultFuzzyDistance); ok {
+		fmt.Fprintf(r.out, "no hits, did you mean: %s\n", corrected)
+	} else {
+		fmt.Fprintln(r.out, "no hits")
+	}
+}
+
+func (r *repl) search(text string) {
+	results := r.rankers[r.mode].Search(text, r.k)
+	if len(results) == 0 {
+		r.suggest(text)
+		return
+	}
+
+	for i, result := range results {
+		doc := &r.index.Documents[result.DocID]
+		fmt.Fprintf(r.out, "%3d. [%d] %.4f %s (%s)\n", i+1, result.DocID, result.Score, doc.Name, r.classNames(doc))
+	}
+}
+
+func (r *repl) boolean(text string) {
+	matches, err := r.engine.Search(text)
+	if err != nil {
+		fmt.Fprintln(r.out, err)
+		return
+	}
+
+	if len(matches) == 0 {
+		r.suggest(text)
+		return
+	}
+
+	for i := 0; i < len(matches) && i < r.k; i++ {
+		fmt.Fprintf(r.out, "[%d] %s (%s)\n", matches[i].DocID, matches[i].Document.Name, r.classNames(matches[i].Document))
+	}
+	fmt.Fprintf(r.out, "%d matching documents\n", len(matches))
+}
+
+func (r *repl) setMode(mode string) {
+	if _, ok := r.rankers[mode]; !ok {
+		fmt.Fprintf(r.out, "unknown mode %s, use bm25 or tfidf\n", mode)
+		return
+	}
+	r.mode = mode
+}
+
+func (r *repl) setK(argument string) {
+	k, err := strconv.Atoi(argument)
+	if err != nil || k <= 0 {
+		fmt.Fprintf(r.out, "invalid number %s\n", argument)
+		return
+	}
+	r.k = k
+}
+
+func (r *repl) parseDocID(argument string) (int32, bool) {
+	docID, err := strconv.Atoi(argument)
+	if err != nil || docID < 0 || docID >= len(r.index.Documents) {
+		fmt.Fprintf(r.out, "no document with id %s\n", argument)
+		return 0, false
+	}
+	if r.index.Documents[docID].Deleted {
+		fmt.Fprintf(r.out, "document %d is deleted\n", docID)
+		return 0, false
+	}
+	return int32(docID), true
+}
+
+func (r *repl) document(argument string) {
+	docID, ok := r.parseDocID(argument)
+	if !ok {
+		return
+	}
+
+	doc := &r.index.Documents[docID]
+	fmt.Fprintf(r.out, "name: %s\nclasses: %s\nlength: %d, unique terms: %d, cluster: %d\n",
+		doc.Name, r.classNames(doc), doc.Length, doc.UniqueLength, doc.ClusterID)
+
+	r.index.LoopOverDocumentPostings(docID, func(posting *indices.Posting) {
+		fmt.Fprintf(r.out, "  %6d %-20s %d\n", posting.Index, r.index.Dictionary.GetInverse(posting.Index), posting.Count)
+	})
+}
+
+func (r *repl) frequency(word string) {
+	var terms []string
+	r.tokeniser.GetTerms(word, func(term string) {
+		terms = append(terms, term)
+	})
+
+	if len(terms) == 0 {
+		fmt.Fprintf(r.out, "%s is a stop word\n", word)
+		return
+	}
+
+	for _, term := range terms {
+		termID := r.index.Dictionary.Find([]byte(term))
+		if termID == -1 || int(termID) >= len(r.index.Inverse.PostingLists) {
+			fmt.Fprintf(r.out, "%s: not in the index\n", term)
+			continue
+		}
+
+		collectionFrequency := 0
+		r.index.LoopOverTermPostings(termID, func(posting *indices.Posting) {
+			collectionFrequency += int(posting.Count)
+		})
+
+		fmt.Fprintf(r.out, "%s (id %d): in %d documents, %d times in total, idf %.4f\n",
+			term, termID, r.index.DocumentFrequency(termID), collectionFrequency, r.index.IDF(termID))
+	}
+}
+
+func (r *repl) cluster(argument string) {
+	docID, ok := r.parseDocID(argument)
+	if !ok {
+		return
+	}
+
+	clusterID := r.index.Documents[docID].ClusterID
+	if clusterID < 0 || clusterID >= len(r.index.Centroids) {
+		fmt.Fprintf(r.out, "document %d isn't in a cluster, was kmeans run on the index?\n", docID)
+		return
+	}
+
+	size := 0
+	classes := make(map[int32]int)
+	for _, doc := range r.index.Documents {
+		if doc.ClusterID == clusterID && !doc.Deleted {
+			size += 1
+			for _, class := range doc.Classes {
+				classes[class] += 1
+			}
+		}
+	}
+
+	fmt.Fprintf(r.out, "document %d is in cluster %d with %d documents, most common classes:\n", docID, clusterID, size)
+	for _, class := range sortDict(classes) {
+		fmt.Fprintf(r.out, "  %-20s %d\n", r.index.ClassNames.GetInverse(class), classes[class])
+	}
+}
+
+// Returns the keys sorted by decreasing value, at most 8 of them
+func sortDict(dict map[int32]int) []int32 {
+	keys := make([]int32, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if dict[keys[i]] != dict[keys[j]] {
+			return dict[keys[i]] > dict[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	if len(keys) > 8 {
+		keys = keys[:8]
+	}
+	return keys
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/bitterfly/search/docstore"
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "search_server"
+	app.Usage = "Loads an index and serves searches over it as JSON"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "index, i",
+			Usage: "File with index",
+			Value: "/tmp/kmeans.gob.gz",
+		},
+		cli.StringFlag{
+			Name:  "stopwords, s",
+			Usage: "Stopwords file, the same one the index was built with",
+			Value: "stopwords",
+		},
+		cli.StringFlag{
+			Name:  "store",
+			Usage: "File with stored documents. If not specified, documents are served without title, body and date",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "address, a",
+			Usage: "Address to listen on",
+			Value: ":8080",
+		},
+		processing.IgnoreMetadataFlag,
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	ti := indices.NewTotalIndex()
+	err := ti.DeserialiseFromFile(c.String("index"))
+	if err != nil {
+		log.Fatalf("unable to load index: %s", err)
+	}
+	ti.Finalise()
+
+	tokeniser, err := processing.NewEnglishTokeniserFromFile(c.String("stopwords"))
+	if err != nil {
+		log.Fatalf("unable to get stopwords: %s", err)
+	}
+
+	processing.CheckOrFatal(ti.Metadata, tokeniser, c.Bool(processing.IgnoreMetadataFlag.Name))
+
+	var store *docstore.Store
+	if c.String("store") != "" {
+		store = docstore.New()
+		err = store.DeserialiseFromFile(c.String("store"))
+		if err != nil {
+			log.Fatalf("unable to load document store: %s", err)
+		}
+	}
+
+	log.Printf("loaded index with %d documents and %d terms", ti.Len(), len(ti.Inverse.PostingLists))
+	log.Printf("index metadata: %s", ti.Metadata)
+
+	server := NewServer(ti, tokeniser, store)
+	log.Printf("listening on %s", c.String("address"))
+	log.Fatal(http.ListenAndServe(c.String("address"), server))
+}
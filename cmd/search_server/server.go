@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitterfly/search/docstore"
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+	"github.com/bitterfly/search/query"
+	"github.com/bitterfly/search/ranking"
+)
+
+const (
+	defaultK     = 10
+	defaultLimit = 100
+)
+
+// Server only ever reads the index (terms are looked up with Find, never Get),
+// so the handlers can run concurrently without locking
+type Server struct {
+	index     *indices.TotalIndex
+	store     *docstore.Store
+	engine    *query.Engine
+	rankers   map[string]*ranking.Ranker
+	snippeter *docstore.Snippeter
+	mux       *http.ServeMux
+}
+
+type Hit struct {
+	ID      int32    `json:"id"`
+	Name    string   `json:"name"`
+	Classes []string `json:"classes"`
+	Cluster int      `json:"cluster"`
+	Score   float64  `json:"score,omitempty"`
+	Snippet string   `json:"snippet,omitempty"`
+}
+
+// Total is the number of matching documents, of which only the first k are in Hits.
+// Counting them for a ranked search would go over all the postings WAND skips,
+// so unless exact=true is asked for it's only a lower bound and TotalExact is false
+type SearchResponse struct {
+	Query      string `json:"query"`
+	Mode       string `json:"mode"`
+	Total      int    `json:"total"`
+	TotalExact bool   `json:"total_exact"`
+	Hits       []Hit  `json:"hits"`
+	DidYouMean string `json:"did_you_mean,omitempty"`
+}
+
+type DocumentResponse struct {
+	Hit
+	Length       int32  `json:"length"`
+	UniqueLength int32  `json:"unique_length"`
+	Title        string `json:"title,omitempty"`
+	Date         string `json:"date,omitempty"`
+	Body         string `json:"body,omitempty"`
+}
+
+type TermResponse struct {
+	ID        int32  `json:"id"`
+	Term      string `json:"term"`
+	Documents int    `json:"documents"`
+}
+
+type ClassResponse struct {
+	ID        int32  `json:"id"`
+	Name      string `json:"name"`
+	Documents int    `json:"documents"`
+}
+
+type ClusterResponse struct {
+	ID        int            `json:"id"`
+	Size      int            `json:"size"`
+	Classes   map[string]int `json:"classes"`
+	TopTerms  []string       `json:"top_terms"`
+	Documents []int32        `json:"documents"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func NewServer(index *indices.TotalIndex, tokeniser processing.Tokeniser, store *docstore.Store) *Server {
+	s := &Server{
+		index:  index,
+		store:  store,
+		engine: query.NewEngine(index, tokeniser),
+		rankers: map[string]*ranking.Ranker{
+			"bm25":  ranking.NewRanker(index, tokeniser, ranking.NewBM25(index, ranking.DefaultK1, ranking.DefaultB)),
+			"tfidf": ranking.NewRanker(index, tokeniser, ranking.NewTFIDF(index)),
+		},
+		snippeter: docstore.NewSnippeter(tokeniser),
+		mux:       http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/search", s.handleSearch)
+	s.mux.HandleFunc("/document/", s.handleDocument)
+	s.mux.HandleFunc("/terms/", s.handleTerms)
+	s.mux.HandleFunc("/classes", s.handleClasses)
+	s.mux.HandleFunc("/clusters/", s.handleCluster)
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("only GET is supported"))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Printf("unable to write response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+// Parses the optional integer query parameter
+func intParameter(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("invalid %s: %s", name, value)
+	}
+	return parsed, nil
+}
+
+// Parses the id after prefix in the path, e.g. /document/42
+func pathID(r *http.Request, prefix string, size int) (int, error) {
+	value := strings.TrimPrefix(r.URL.Path, prefix)
+	id, err := strconv.Atoi(value)
+	if err != nil || id < 0 || id >= size {
+		return 0, fmt.Errorf("no such id: %s", value)
+	}
+	return id, nil
+}
+
+func (s *Server) hit(docID int32) Hit {
+	doc := &s.index.Documents[docID]
+
+	classes := make([]string, len(doc.Classes))
+	for i, class := range doc.Classes {
+		classes[i] = string(s.index.ClassNames.GetInverse(class))
+	}
+
+	return Hit{
+		ID:      docID,
+		Name:    doc.Name,
+		Classes: classes,
+		Cluster: doc.ClusterID,
+	}
+}
+
+func (s *Server) snippet(docID int32, text string) string {
+	if s.store == nil {
+		return ""
+	}
+
+	doc, err := s.store.Get(docID)
+	if err != nil {
+		return ""
+	}
+	return s.snippeter.Snippet(doc.Body, text)
+}
+
+// /search?q=<query>&mode=<boolean|bm25|tfidf>&k=<number of hits>&exact=<true to count all ranked matches>
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	text := r.URL.Query().Get("q")
+	if text == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing query parameter q"))
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = "bm25"
+	}
+
+	k, err := intParameter(r, "k", defaultK)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	response := SearchResponse{
+		Query: text,
+		Mode:  mode,
+		Hits:  []Hit{},
+	}
+
+	if mode == "boolean" {
+		matches, err := s.engine.Search(text)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		response.Total = len(matches)
+		response.TotalExact = true
+		for i := 0; i < len(matches) && i < k; i++ {
+			hit := s.hit(matches[i].DocID)
+			hit.Snippet = s.snippet(matches[i].DocID, text)
+			response.Hits = append(response.Hits, hit)
+		}
+	} else {
+		ranker, ok := s.rankers[mode]
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("unknown mode %s", mode))
+			return
+		}
+
+		results := ranker.Search(text, k)
+		if r.URL.Query().Get("exact") == "true" {
+			response.Total = ranker.Count(text)
+			response.TotalExact = true
+		} else {
+			response.Total = ranker.EstimateCount(text)
+			if response.Total < len(results) {
+				response.Total = len(results)
+			}
+		}
+		for _, result := range results {
+			hit := s.hit(result.DocID)
+			hit.Score = result.Score
+			hit.Snippet = s.snippet(result.DocID, text)
+			response.Hits = append(response.Hits, hit)
+		}
+	}
+
+	if response.Total == 0 {
+		response.DidYouMean, _ = s.engine.DidYouMean(text, query.DefaultFuzzyDistance)
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// /document/<id>
+func (s *Server) handleDocument(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "/document/", len(s.index.Documents))
+	if err == nil && s.index.Documents[id].Deleted {
+		err = fmt.Errorf("document %d is deleted", id)
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	doc := &s.index.Documents[id]
+	response := DocumentResponse{
+		Hit:          s.hit(int32(id)),
+		Length:       doc.Length,
+		UniqueLength: doc.UniqueLength,
+	}
+
+	if s.store != nil {
+		stored, err := s.store.Get(int32(id))
+		if err == nil {
+			response.Title = stored.Title
+			response.Date = stored.Date
+			response.Body = stored.Body
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// /terms/<prefix>?limit=<number of terms>
+// Terms are sorted by the number of documents they're in
+func (s *Server) handleTerms(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimPrefix(r.URL.Path, "/terms/")
+
+	limit, err := intParameter(r, "limit", defaultLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	terms := []TermResponse{}
+	s.index.Dictionary.WalkPrefix([]byte(strings.ToLower(prefix)), func(term []byte, termID int32) {
+		if int(termID) < len(s.index.Inverse.PostingLists) && s.index.DocumentFrequency(termID) > 0 {
+			terms = append(terms, TermResponse{
+				ID:        termID,
+				Term:      string(term),
+				Documents: s.index.DocumentFrequency(termID),
+			})
+		}
+	})
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Documents != terms[j].Documents {
+			return terms[i].Documents > terms[j].Documents
+		}
+		return terms[i].Term < terms[j].Term
+	})
+
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+
+	writeJSON(w, http.StatusOK, terms)
+}
+
+// /classes
+func (s *Server) handleClasses(w http.ResponseWriter, r *http.Request) {
+	counts := make([]int, s.index.ClassNames.Size)
+	for _, doc := range s.index.Documents {
+		if doc.Deleted {
+			continue
+		}
+		for _, class := range doc.Classes {
+			counts[class] += 1
+		}
+	}
+
+	classes := make([]ClassResponse, len(counts))
+	for i := range classes {
+		classes[i] = ClassResponse{
+			ID:        int32(i),
+			Name:      string(s.index.ClassNames.GetInverse(int32(i))),
+			Documents: counts[i],
+		}
+	}
+
+	writeJSON(w, http.StatusOK, classes)
+}
+
+// /clusters/<id>?limit=<number of top terms>
+func (s *Server) handleCluster(w http.ResponseWriter, r *http.Request) {
+	id, err := pathID(r, "/clusters/", len(s.index.Centroids))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	limit, err := intParameter(r, "limit", defaultK)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	response := ClusterResponse{
+		ID:        id,
+		Classes:   make(map[string]int),
+		Documents: []int32{},
+	}
+
+	for docID, doc := range s.index.Documents {
+		if doc.ClusterID != id || doc.Deleted {
+			continue
+		}
+		response.Documents = append(response.Documents, int32(docID))
+		for _, class := range doc.Classes {
+			response.Classes[string(s.index.ClassNames.GetInverse(class))] += 1
+		}
+	}
+	response.Size = len(response.Documents)
+
+	centroid := s.index.Centroids[id]
+	termIDs := make([]int32, len(centroid))
+	for i := range termIDs {
+		termIDs[i] = int32(i)
+	}
+	sort.Slice(termIDs, func(i, j int) bool { return centroid[termIDs[i]] > centroid[termIDs[j]] })
+
+	for i := 0; i < len(termIDs) && i < limit && centroid[termIDs[i]] > 0; i++ {
+		response.TopTerms = append(response.TopTerms, string(s.index.Dictionary.GetInverse(termIDs[i])))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
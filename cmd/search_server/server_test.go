@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bitterfly/search/docstore"
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing/processingtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTokeniser = processingtest.NewTokeniser("the")
+
+var texts = []struct {
+	text    string
+	classes []string
+}{
+	{"oil prices rise as oil exports fall", []string{"crude"}},
+	{"gold prices rise", []string{"gold"}},
+	{"oil", []string{"crude"}},
+	{"the bank raises interest rates", []string{"money"}},
+	{"interest in oil and gold is low", []string{"crude", "gold"}},
+}
+
+func makeServer() *Server {
+	ti := indices.NewTotalIndex()
+	store := docstore.New()
+
+	for i, text := range texts {
+		doc := indices.NewInfoAndTerms()
+		doc.Name = text.text
+		doc.Body = text.text
+		doc.Classes = text.classes
+		testTokeniser.GetTerms(text.text, doc.AddTerm)
+		ti.Add(doc)
+		store.Add(int32(i), doc)
+	}
+
+	ti.Centroids = [][]float64{make([]float64, ti.Dictionary.Size)}
+	ti.Centroids[0][ti.Dictionary.Find([]byte("oil"))] = 2
+	ti.Centroids[0][ti.Dictionary.Find([]byte("prices"))] = 1
+	for _, docID := range []int{0, 2, 4} {
+		ti.Documents[docID].ClusterID = 0
+	}
+
+	ti.Finalise()
+	return NewServer(ti, testTokeniser, store)
+}
+
+// Serves the request and decodes the JSON response into value
+func get(t *testing.T, s *Server, method string, url string, value interface{}) int {
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, httptest.NewRequest(method, url, nil))
+
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Nil(t, json.NewDecoder(recorder.Body).Decode(value))
+	return recorder.Code
+}
+
+func hitIDs(hits []Hit) []int32 {
+	ids := make([]int32, len(hits))
+	for i := range hits {
+		ids[i] = hits[i].ID
+	}
+	return ids
+}
+
+func TestSearch_Boolean(t *testing.T) {
+	assert := assert.New(t)
+	s := makeServer()
+
+	var response SearchResponse
+	assert.Equal(http.StatusOK, get(t, s, "GET", "/search?mode=boolean&q=oil+OR+gold&k=2", &response))
+	assert.Equal("boolean", response.Mode)
+	assert.Equal(4, response.Total)
+	assert.True(response.TotalExact)
+	assert.Equal([]int32{0, 1}, hitIDs(response.Hits))
+	assert.Equal([]string{"crude"}, response.Hits[0].Classes)
+	assert.Equal(0, response.Hits[0].Cluster)
+	assert.Contains(response.Hits[0].Snippet, "oil")
+	assert.Empty(response.DidYouMean)
+}
+
+func TestSearch_Ranked(t *testing.T) {
+	assert := assert.New(t)
+	s := makeServer()
+
+	for _, mode := range []string{"bm25", "tfidf"} {
+		var response SearchResponse
+		assert.Equal(http.StatusOK, get(t, s, "GET", "/search?mode="+mode+"&q=oil+gold&k=1", &response))
+		assert.Equal(mode, response.Mode)
+		// the documents of the most frequent term
+		assert.Equal(3, response.Total)
+		assert.False(response.TotalExact)
+		assert.Len(response.Hits, 1)
+		assert.True(response.Hits[0].Score > 0)
+
+		// all the matching documents, not only the returned ones
+		assert.Equal(http.StatusOK, get(t, s, "GET", "/search?mode="+mode+"&q=oil+gold&k=1&exact=true", &response))
+		assert.Equal(4, response.Total)
+		assert.True(response.TotalExact)
+	}
+
+	var response SearchResponse
+	assert.Equal(http.StatusOK, get(t, s, "GET", "/search?q=oli", &response))
+	assert.Equal("bm25", response.Mode)
+	assert.Equal(0, response.Total)
+	assert.Empty(response.Hits)
+	assert.Equal("oil", response.DidYouMean)
+}
+
+func TestSearch_Errors(t *testing.T) {
+	assert := assert.New(t)
+	s := makeServer()
+
+	for url, status := range map[string]int{
+		"/search":                              http.StatusBadRequest,
+		"/search?q=oil&k=-1":                   http.StatusBadRequest,
+		"/search?q=oil&k=many":                 http.StatusBadRequest,
+		"/search?q=oil&mode=magic":             http.StatusBadRequest,
+		"/search?q=oil+AND+(gold&mode=boolean": http.StatusBadRequest,
+		"/document/5":                          http.StatusNotFound,
+		"/document/oil":                        http.StatusNotFound,
+		"/clusters/1":                          http.StatusNotFound,
+		"/terms/o?limit=-2":                    http.StatusBadRequest,
+	} {
+		var response errorResponse
+		assert.Equal(status, get(t, s, "GET", url, &response), url)
+		assert.NotEmpty(response.Error, url)
+	}
+
+	var response errorResponse
+	assert.Equal(http.StatusMethodNotAllowed, get(t, s, "POST", "/search?q=oil", &response))
+	assert.NotEmpty(response.Error)
+}
+
+func TestDocument(t *testing.T) {
+	assert := assert.New(t)
+	s := makeServer()
+
+	var response DocumentResponse
+	assert.Equal(http.StatusOK, get(t, s, "GET", "/document/4", &response))
+	assert.Equal(int32(4), response.ID)
+	assert.Equal([]string{"crude", "gold"}, response.Classes)
+	assert.Equal(int32(7), response.Length)
+	assert.Equal(texts[4].text, response.Body)
+}
+
+func TestTerms(t *testing.T) {
+	assert := assert.New(t)
+	s := makeServer()
+
+	var terms []TermResponse
+	assert.Equal(http.StatusOK, get(t, s, "GET", "/terms/o", &terms))
+	assert.Len(terms, 1)
+	assert.Equal("oil", terms[0].Term)
+	assert.Equal(3, terms[0].Documents)
+
+	// by the number of documents, then alphabetically
+	assert.Equal(http.StatusOK, get(t, s, "GET", "/terms/r?limit=2", &terms))
+	assert.Equal([]TermResponse{
+		{ID: s.index.Dictionary.Find([]byte("rise")), Term: "rise", Documents: 2},
+		{ID: s.index.Dictionary.Find([]byte("raises")), Term: "raises", Documents: 1},
+	}, terms)
+}
+
+func TestClassesAndClusters(t *testing.T) {
+	assert := assert.New(t)
+	s := makeServer()
+
+	var classes []ClassResponse
+	assert.Equal(http.StatusOK, get(t, s, "GET", "/classes", &classes))
+	assert.Equal([]ClassResponse{
+		{ID: 0, Name: "crude", Documents: 3},
+		{ID: 1, Name: "gold", Documents: 2},
+		{ID: 2, Name: "money", Documents: 1},
+	}, classes)
+
+	var cluster ClusterResponse
+	assert.Equal(http.StatusOK, get(t, s, "GET", "/clusters/0?limit=5", &cluster))
+	assert.Equal(3, cluster.Size)
+	assert.Equal([]int32{0, 2, 4}, cluster.Documents)
+	assert.Equal(map[string]int{"crude": 3, "gold": 1}, cluster.Classes)
+	assert.Equal([]string{"oil", "prices"}, cluster.TopTerms)
+}
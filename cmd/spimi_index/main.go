@@ -0,0 +1,134 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/bitterfly/search/documents"
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+	"github.com/bitterfly/search/spimi"
+	"github.com/bitterfly/search/utils"
+	"github.com/urfave/cli"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "spimi_index"
+	app.Usage = "Parse Reuters XML documents and index them into a memory mapped index without keeping the postings in memory"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "xmldir, d",
+			Usage: "Directory with Reuters XML files",
+			Value: ".",
+		},
+		cli.StringFlag{
+			Name:  "stopwords, s",
+			Usage: "Stopwords file. If not specified, defaults to ${xmldir}/stopwords",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "output, o",
+			Usage: "File to write index to",
+			Value: "/tmp/index.idx",
+		},
+		cli.IntFlag{
+			Name:  "memory, m",
+			Usage: "Megabytes of postings to collect before writing them to a temporary file",
+			Value: spimi.DefaultMemoryBudget >> 20,
+		},
+		cli.StringFlag{
+			Name:  "tempdir, t",
+			Usage: "Directory for the temporary files. If not specified, the system one is used",
+			Value: "",
+		},
+		cli.BoolFlag{
+			Name:  "classy, y",
+			Usage: "Include documents which have >=1 assigned classes",
+		},
+		cli.BoolFlag{
+			Name:  "classless, n",
+			Usage: "Include documents which have no classes",
+		},
+	}
+
+	app.Action = mainCommand
+
+	app.Run(os.Args)
+}
+
+func mainCommand(c *cli.Context) {
+	files := make(chan string, 200)
+	docs := make(chan *documents.Document, 2000)
+	infosAndTerms := make(chan *indices.InfoAndTerms, 2000)
+
+	stopWordsFile := c.String("stopwords")
+	if stopWordsFile == "" {
+		stopWordsFile = filepath.Join(c.String("xmldir"), "stopwords")
+	}
+
+	tokeniser, err := processing.NewEnglishTokeniserFromFile(stopWordsFile)
+	if err != nil {
+		log.Fatalf("unable to get stopwords: %s", err)
+	}
+
+	builder, err := spimi.NewBuilder(c.String("output"), spimi.Options{
+		MemoryBudget: int64(c.Int("memory")) << 20,
+		TempDir:      c.String("tempdir"),
+	})
+	if err != nil {
+		log.Fatalf("Unable to start index: %s", err)
+	}
+
+	go func() {
+		matches, err := filepath.Glob(filepath.Join(c.String("xmldir"), "*.xml"))
+		if err != nil {
+			log.Fatalf("unable to get files in folder %s: %s", c.String("xmldir"), err)
+		}
+		for i := range matches {
+			files <- matches[i]
+		}
+		close(files)
+	}()
+
+	go func() {
+		utils.Parallel(func() {
+			documents.NewReutersParser().ParseFiles(files, docs)
+		}, runtime.NumCPU())
+		close(docs)
+	}()
+
+	go func() {
+		utils.Parallel(func() {
+			processing.CountInDocuments(
+				docs,
+				tokeniser,
+				infosAndTerms,
+				c.Bool("classless"),
+				c.Bool("classy"),
+			)
+		}, runtime.NumCPU())
+		close(infosAndTerms)
+	}()
+
+	for it := range infosAndTerms {
+		_, err = builder.Add(it)
+		if err != nil {
+			log.Printf("Unable to add document: %s", err)
+		}
+	}
+
+	builder.Metadata.Tokeniser, builder.Metadata.StopWordsHash = processing.Describe(tokeniser)
+	builder.Metadata.Source = c.String("xmldir")
+	builder.Metadata.Classy = c.Bool("classy")
+	builder.Metadata.Classless = c.Bool("classless")
+
+	err = builder.Close()
+	if err != nil {
+		log.Fatalf("Unable to write index: %s", err)
+	}
+
+	log.Printf("Wrote %d documents to %s", builder.Len(), c.String("output"))
+}
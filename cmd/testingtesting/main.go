@@ -6,9 +6,12 @@ import (
 	"path/filepath"
 	"runtime"
 
+	"github.com/bitterfly/search/docstore"
 	"github.com/bitterfly/search/documents"
 	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/kmeans"
 	"github.com/bitterfly/search/processing"
+	"github.com/bitterfly/search/ranking"
 	"github.com/bitterfly/search/utils"
 	"github.com/urfave/cli"
 )
@@ -33,6 +36,25 @@ func main() {
 			Usage: "File to write index to",
 			Value: "/tmp/index.gob.gz",
 		},
+		cli.StringFlag{
+			Name:  "append, a",
+			Usage: "Existing index to add the documents to, the result is still written to output",
+			Value: "",
+		},
+		cli.StringFlag{
+			Name:  "store",
+			Usage: "File to write the stored documents (title, body, date, classes) to. If not specified, they aren't stored. When appending, the documents are added to it",
+			Value: "",
+		},
+		cli.IntFlag{
+			Name:  "workers, w",
+			Usage: "Number of shards to index into in parallel before merging them, e.g. the number of CPUs. With more than one the document ids depend on which shard got which document and aren't in corpus order, and with --store all the documents are kept in memory until the shards are merged",
+			Value: 1,
+		},
+		cli.BoolFlag{
+			Name:  "compress",
+			Usage: "Store the inverse posting lists delta and varint encoded",
+		},
 		cli.BoolFlag{
 			Name:  "classy, y",
 			Usage: "Include documents which have >=1 assigned classes",
@@ -63,6 +85,19 @@ func mainCommand(c *cli.Context) {
 		log.Fatal("unable to get stopwords: %s", err)
 	}
 
+	index := indices.NewTotalIndex()
+	if c.String("append") != "" {
+		err = index.DeserialiseFromFile(c.String("append"))
+		if err != nil {
+			log.Fatalf("Unable to load index: %s", err)
+		}
+
+		// appending with another tokeniser would mix differently tokenised documents, so it can't be ignored
+		processing.CheckOrFatal(index.Metadata, tokeniser, false)
+	}
+	// the first new document
+	appended := int32(len(index.Documents))
+
 	go func() {
 		GetXMLs(c.String("xmldir"), files)
 		close(files)
@@ -88,9 +123,74 @@ func mainCommand(c *cli.Context) {
 		close(infosAndTerms)
 	}()
 
-	index := indices.NewTotalIndex()
-	index.AddMany(infosAndTerms)
-	index.Verify()
+	workers := c.Int("workers")
+	if c.String("store") == "" {
+		if workers > 1 {
+			err = index.AddManyParallel(infosAndTerms, workers)
+			if err != nil {
+				log.Fatalf("Unable to index documents: %s", err)
+			}
+		} else {
+			index.AddMany(infosAndTerms)
+		}
+	} else {
+		store := docstore.New()
+		if _, statErr := os.Stat(c.String("store")); c.String("append") != "" && statErr == nil {
+			err = store.DeserialiseFromFile(c.String("store"))
+			if err != nil {
+				log.Fatalf("Unable to load document store: %s", err)
+			}
+		}
+
+		added := func(docID int32, it *indices.InfoAndTerms) {
+			err := store.Add(docID, it)
+			if err != nil {
+				log.Printf("Unable to store document %s: %s", it.Name, err)
+			}
+		}
+		if workers > 1 {
+			err = index.AddManyParallelWith(infosAndTerms, workers, added)
+			if err != nil {
+				log.Fatalf("Unable to index documents: %s", err)
+			}
+		} else {
+			index.AddManyWith(infosAndTerms, added)
+		}
+
+		err = store.SerialiseToFile(c.String("store"))
+		if err != nil {
+			log.Fatalf("Unable to serialise document store: %s", err)
+		}
+	}
+	index.Finalise()
+	index.VerifyOrFatal()
+
+	if appended == 0 {
+		processing.SetMetadata(index, tokeniser)
+		index.Metadata.Source = c.String("xmldir")
+		index.Metadata.Classy = c.Bool("classy")
+		index.Metadata.Classless = c.Bool("classless")
+	} else {
+		index.Metadata.Source += ", " + c.String("xmldir")
+		// like when merging indices, set if any of the documents were filtered so
+		index.Metadata.Classy = index.Metadata.Classy || c.Bool("classy")
+		index.Metadata.Classless = index.Metadata.Classless || c.Bool("classless")
+
+		// an index with centroids has been through k_means_index, which normalises it
+		if len(index.Centroids) != 0 {
+			index.NormaliseFrom(appended)
+			kmeans.AssignClusters(index, appended)
+		}
+		log.Printf("Appended %d documents", len(index.Documents)-int(appended))
+	}
+
+	// upper bounds for pruning ranked search
+	ranking.StoreMaxScores(index, ranking.NewBM25(index, ranking.DefaultK1, ranking.DefaultB))
+	ranking.StoreMaxScores(index, ranking.NewTFIDF(index))
+
+	if c.Bool("compress") {
+		index.Inverse.Compress()
+	}
 
 	err = index.SerialiseToFile(c.String("output"))
 	if err != nil {
@@ -0,0 +1,287 @@
+package diskindex
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing/processingtest"
+	"github.com/bitterfly/search/query"
+	"github.com/bitterfly/search/ranking"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeIndex() *indices.TotalIndex {
+	ti := indices.NewTotalIndex()
+
+	texts := [][]string{
+		{"oil", "prices", "rise", "oil"},
+		{"gold", "prices"},
+		{"oil"},
+		{"interest", "rates", "rise"},
+	}
+	classes := [][]string{{"crude"}, {"gold", "money"}, {}, {"money"}}
+
+	for i, text := range texts {
+		doc := indices.NewInfoAndTerms()
+		doc.Name = text[0]
+		doc.Classes = classes[i]
+		for _, term := range text {
+			doc.AddTerm(term)
+		}
+		ti.Add(doc)
+	}
+
+	ti.Finalise()
+	ti.Centroids = [][]float64{{1, 0.5}, {0.25, -2}}
+	ti.Documents[3].ClusterID = 1
+	ti.Metadata.Tokeniser = "test"
+
+	return ti
+}
+
+func writeTempFile(t *testing.T, ti *indices.TotalIndex) (string, func()) {
+	dir, err := ioutil.TempDir("", "diskindex")
+	if err != nil {
+		t.Fatalf("unable to create directory: %s", err)
+	}
+
+	filename := filepath.Join(dir, "index")
+	err = WriteFile(filename, ti)
+	if err != nil {
+		t.Fatalf("unable to write index: %s", err)
+	}
+
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+func cursorDocuments(cursor *indices.Cursor) []int32 {
+	var docs []int32
+	for cursor.Next() {
+		docs = append(docs, cursor.Posting().Index)
+	}
+	return docs
+}
+
+func TestOpen(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeIndex()
+	filename, remove := writeTempFile(t, ti)
+	defer remove()
+
+	index, err := Open(filename)
+	if err != nil {
+		t.Fatalf("unable to open index: %s", err)
+	}
+	defer index.Close()
+
+	assert.Equal(4, index.Len())
+	assert.Equal(int(ti.Dictionary.Size), index.NumTerms())
+
+	oil := index.Find([]byte("oil"))
+	assert.Equal(ti.Dictionary.Find([]byte("oil")), oil)
+	assert.Equal([]byte("oil"), index.Term(oil))
+	assert.Equal(int32(-1), index.Find([]byte("silver")))
+	assert.Equal(int32(-1), index.Find([]byte("")))
+
+	assert.Equal(2, index.DocumentFrequency(oil))
+	assert.Equal([]int32{0, 2}, cursorDocuments(index.TermCursor(oil)))
+
+	cursor := index.TermCursor(oil)
+	assert.True(cursor.Next())
+	assert.Equal(int32(2), cursor.Posting().Count)
+	assert.Equal([]int32{0, 3}, cursor.Posting().Positions)
+
+	assert.Len(cursorDocuments(index.DocumentCursor(3)), 3)
+
+	var terms []string
+	index.WalkPrefix([]byte("r"), func(term []byte, termID int32) {
+		assert.Equal(ti.Dictionary.Find(term), termID)
+		terms = append(terms, string(term))
+	})
+	assert.Equal([]string{"rates", "rise"}, terms)
+
+	doc, err := index.Document(1)
+	assert.Nil(err)
+	assert.Equal(ti.Documents[1], doc)
+
+	doc, err = index.Document(3)
+	assert.Nil(err)
+	assert.Equal(1, doc.ClusterID)
+
+	_, err = index.Document(4)
+	assert.NotNil(err)
+
+	assert.Equal(ti.ClassNames.Find([]byte("money")), index.FindClass([]byte("money")))
+	assert.Equal([]byte("gold"), index.ClassName(index.FindClass([]byte("gold"))))
+	assert.Equal(3, index.NumClasses())
+
+	assert.Equal(ti.Centroids, index.Centroids())
+}
+
+func TestLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeIndex()
+	filename, remove := writeTempFile(t, ti)
+	defer remove()
+
+	index, err := Open(filename)
+	if err != nil {
+		t.Fatalf("unable to open index: %s", err)
+	}
+	defer index.Close()
+
+	loaded, err := index.Load()
+	assert.Nil(err)
+
+	assert.Equal(ti.Documents, loaded.Documents)
+	assert.Equal(ti.Centroids, loaded.Centroids)
+	assert.Equal("test", loaded.Metadata.Tokeniser)
+	assert.Equal(ti.Dictionary.Inverse, loaded.Dictionary.Inverse)
+	assert.Equal(ti.ClassNames.Inverse, loaded.ClassNames.Inverse)
+
+	for termID := range ti.Inverse.PostingLists {
+		assert.Equal(ti.Inverse.PostingLists[termID].Len, loaded.Inverse.PostingLists[termID].Len)
+		assert.Equal(
+			cursorDocuments(ti.TermCursor(int32(termID))),
+			cursorDocuments(loaded.TermCursor(int32(termID))),
+		)
+	}
+
+	assert.Nil(loaded.Forward.Decompress())
+	assert.Nil(loaded.Inverse.Decompress())
+	assert.Empty(loaded.Verify())
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeIndex()
+	filename, remove := writeTempFile(t, ti)
+	defer remove()
+
+	index, err := Open(filename)
+	if err != nil {
+		t.Fatalf("unable to open index: %s", err)
+	}
+	defer index.Close()
+
+	var patterns []string
+	index.WalkPattern([]byte("r*s"), func(term []byte, termID int32) {
+		patterns = append(patterns, string(term))
+	})
+	assert.Equal([]string{"rates"}, patterns)
+
+	fuzzy := make(map[string]int)
+	index.WalkFuzzy([]byte("rice"), 1, func(term []byte, termID int32, distance int) {
+		fuzzy[string(term)] = distance
+	})
+	assert.Equal(map[string]int{"rise": 1}, fuzzy)
+
+	// the mapped index is searched without loading it and finds the same as the one in memory
+	tokeniser := processingtest.NewTokeniser()
+	mapped := query.NewEngine(index, tokeniser)
+	memory := query.NewEngine(ti, tokeniser)
+	for _, text := range []string{"oil", "prices AND NOT gold", "r*", "oli~1", "\"oil prices\"", "NOT rise"} {
+		expected, err := memory.Search(text)
+		assert.Nil(err)
+		actual, err := mapped.Search(text)
+		assert.Nil(err)
+		assert.Equal(expected, actual, "query %s", text)
+	}
+
+	for _, scorer := range []func(indices.Searchable) ranking.Scorer{
+		func(index indices.Searchable) ranking.Scorer { return ranking.NewTFIDF(index) },
+		func(index indices.Searchable) ranking.Scorer {
+			return ranking.NewBM25(index, ranking.DefaultK1, ranking.DefaultB)
+		},
+	} {
+		mapped := ranking.NewRanker(index, tokeniser, scorer(index))
+		memory := ranking.NewRanker(ti, tokeniser, scorer(ti))
+		for _, text := range []string{"oil", "oil prices rise", "interest gold"} {
+			assert.Equal(memory.Search(text, 3), mapped.Search(text, 3), "query %s", text)
+		}
+	}
+}
+
+func TestAbort(t *testing.T) {
+	assert := assert.New(t)
+
+	filename, remove := writeTempFile(t, makeIndex())
+	defer remove()
+
+	w, err := Create(filename)
+	assert.Nil(err)
+	assert.Nil(w.WriteMetadata(makeIndex().Metadata))
+	w.Abort()
+
+	_, err = os.Stat(filename)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestOpen_Corrupt(t *testing.T) {
+	assert := assert.New(t)
+
+	filename, remove := writeTempFile(t, makeIndex())
+	defer remove()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unable to read index: %s", err)
+	}
+
+	corrupt := func(change func(data []byte) []byte) error {
+		changed := change(append([]byte(nil), data...))
+		_, err := parse(changed)
+		return err
+	}
+
+	assert.Nil(corrupt(func(data []byte) []byte { return data }))
+	assert.NotNil(corrupt(func(data []byte) []byte { return data[:10] }))
+	assert.NotNil(corrupt(func(data []byte) []byte { return data[:len(data)-8] }))
+	assert.NotNil(corrupt(func(data []byte) []byte { data[0] = 'X'; return data }))
+	assert.NotNil(corrupt(func(data []byte) []byte { data[8] = Version + 1; return data }))
+
+	// no sections
+	assert.NotNil(corrupt(func(data []byte) []byte { data[12] = 0; return data }))
+}
+
+func TestDocument_Corrupt(t *testing.T) {
+	assert := assert.New(t)
+
+	filename, remove := writeTempFile(t, makeIndex())
+	defer remove()
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("unable to read index: %s", err)
+	}
+
+	// changes the field of the first document record at offset
+	corrupt := func(offset int, value uint64, size int) error {
+		index, err := parse(append([]byte(nil), data...))
+		if err != nil {
+			t.Fatalf("unable to parse index: %s", err)
+		}
+
+		if size == 8 {
+			binary.LittleEndian.PutUint64(index.documents.records[offset:], value)
+		} else {
+			binary.LittleEndian.PutUint32(index.documents.records[offset:], uint32(value))
+		}
+		_, err = index.Document(0)
+		return err
+	}
+
+	// the name and the classes, with offsets which wrap around when the length is added
+	assert.NotNil(corrupt(12, math.MaxUint64-1, 8))
+	assert.NotNil(corrupt(20, math.MaxUint32, 4))
+	assert.NotNil(corrupt(24, math.MaxUint64-3, 8))
+	assert.NotNil(corrupt(32, math.MaxUint32, 4))
+}
@@ -0,0 +1,84 @@
+// Package diskindex stores a TotalIndex in a binary file which can be memory mapped
+// and queried without decoding it first.
+//
+// The file starts with a header: the magic bytes, the format version, the number of sections
+// and a table of up to maxSections entries with the kind, offset and length of each section.
+// All numbers are little endian.
+//
+// Lists sections (the inverse and forward index) are the posting lists encoded with
+// indices.EncodePostings one after the other, followed by their offsets and their count.
+// Strings sections (the dictionary and the class names) are the same, but the offsets are
+// followed by the ids sorted by string, so strings can be looked up with binary search.
+// The documents section is the number of documents, a fixed size record for each one
+// and the names and classes they point to. The centroids section is the number of
+// centroids, their dimension and the coordinates. The metadata section is indices.Metadata
+// encoded with gob.
+package diskindex
+
+import (
+	"encoding/binary"
+)
+
+var magic = [8]byte{'S', 'E', 'A', 'R', 'C', 'H', 'I', 'X'}
+
+const Version = 1
+
+type SectionKind uint32
+
+const (
+	SectionDictionary SectionKind = iota + 1
+	SectionClasses
+	SectionInverse
+	SectionForward
+	SectionDocuments
+	SectionCentroids
+	SectionMetadata
+)
+
+const (
+	maxSections = 16
+
+	// magic, version, number of sections
+	headerSize = 8 + 4 + 4
+	// kind, reserved, offset, length
+	sectionEntrySize = 4 + 4 + 8 + 8
+	tableEnd         = headerSize + maxSections*sectionEntrySize
+
+	// length, unique length, cluster, name offset, name length, classes offset, number of classes
+	documentSize = 4 + 4 + 4 + 8 + 4 + 8 + 4
+)
+
+type section struct {
+	kind   SectionKind
+	offset uint64
+	length uint64
+}
+
+func (s SectionKind) String() string {
+	switch s {
+	case SectionDictionary:
+		return "dictionary"
+	case SectionClasses:
+		return "classes"
+	case SectionInverse:
+		return "inverse"
+	case SectionForward:
+		return "forward"
+	case SectionDocuments:
+		return "documents"
+	case SectionCentroids:
+		return "centroids"
+	case SectionMetadata:
+		return "metadata"
+	default:
+		return "unknown"
+	}
+}
+
+func uint64At(data []byte, offset uint64) uint64 {
+	return binary.LittleEndian.Uint64(data[offset:])
+}
+
+func uint32At(data []byte, offset uint64) uint32 {
+	return binary.LittleEndian.Uint32(data[offset:])
+}
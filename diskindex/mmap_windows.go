@@ -0,0 +1,16 @@
+package diskindex
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+// There's no mmap, so the file is read into memory
+func mmap(file *os.File) ([]byte, func() error, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return data, func() error { return nil }, nil
+}
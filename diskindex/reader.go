@@ -0,0 +1,480 @@
+package diskindex
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/trie"
+)
+
+// Index is an index file opened with Open.
+// Nothing is decoded up front, the slices it returns point into the mapped file
+// and are only valid until Close.
+type Index struct {
+	data  []byte
+	unmap func() error
+
+	dictionary stringTable
+	classes    stringTable
+	inverse    listTable
+	forward    listTable
+	documents  documentTable
+	centroids  []byte
+	metadata   []byte
+}
+
+var _ indices.Searchable = (*Index)(nil)
+
+type listTable struct {
+	data    []byte
+	offsets []byte
+	count   uint64
+}
+
+type stringTable struct {
+	listTable
+	sorted []byte
+}
+
+type documentTable struct {
+	records []byte
+	blob    []byte
+	count   uint64
+}
+
+// Open maps the index file into memory and checks its header and the sizes of its sections
+func Open(filename string) (*Index, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %s", err)
+	}
+	defer file.Close()
+
+	data, unmap, err := mmap(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to map file: %s", err)
+	}
+
+	index, err := parse(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+
+	index.unmap = unmap
+	return index, nil
+}
+
+func (i *Index) Close() error {
+	i.data = nil
+	return i.unmap()
+}
+
+func parse(data []byte) (*Index, error) {
+	if len(data) < tableEnd || !bytes.Equal(data[:len(magic)], magic[:]) {
+		return nil, fmt.Errorf("not an index file")
+	}
+
+	if version := uint32At(data, 8); version != Version {
+		return nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	count := uint32At(data, 12)
+	if count > maxSections {
+		return nil, fmt.Errorf("too many sections: %d", count)
+	}
+
+	index := &Index{data: data}
+	found := make(map[SectionKind]bool)
+
+	for s := uint64(0); s < uint64(count); s++ {
+		entry := headerSize + s*sectionEntrySize
+		kind := SectionKind(uint32At(data, entry))
+		offset := uint64At(data, entry+8)
+		length := uint64At(data, entry+16)
+
+		if offset < tableEnd || offset > uint64(len(data)) || length > uint64(len(data))-offset {
+			return nil, fmt.Errorf("section %s is out of the file", kind)
+		}
+		content := data[offset : offset+length]
+
+		var err error
+		switch kind {
+		case SectionDictionary:
+			index.dictionary, err = parseStrings(content)
+		case SectionClasses:
+			index.classes, err = parseStrings(content)
+		case SectionInverse:
+			index.inverse, err = parseLists(content)
+		case SectionForward:
+			index.forward, err = parseLists(content)
+		case SectionDocuments:
+			index.documents, err = parseDocuments(content)
+		case SectionCentroids:
+			index.centroids, err = parseCentroids(content)
+		case SectionMetadata:
+			index.metadata = content
+		default:
+			// unknown sections are skipped, so that newer files can still be read
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("corrupt section %s: %s", kind, err)
+		}
+		found[kind] = true
+	}
+
+	for _, kind := range []SectionKind{SectionDictionary, SectionClasses, SectionInverse, SectionForward, SectionDocuments} {
+		if !found[kind] {
+			return nil, fmt.Errorf("missing section %s", kind)
+		}
+	}
+
+	return index, nil
+}
+
+// Splits the items from their offsets, which end after extra bytes per item and the count
+func parseItems(content []byte, extra uint64) (listTable, error) {
+	if len(content) < 8 {
+		return listTable{}, fmt.Errorf("too short")
+	}
+
+	count := uint64At(content, uint64(len(content)-8))
+	tables := (count+1)*8 + count*extra + 8
+	if count > uint64(len(content)) || tables > uint64(len(content)) {
+		return listTable{}, fmt.Errorf("invalid count %d", count)
+	}
+
+	start := uint64(len(content)) - tables
+	return listTable{
+		data:    content[:start],
+		offsets: content[start : start+(count+1)*8],
+		count:   count,
+	}, nil
+}
+
+func parseLists(content []byte) (listTable, error) {
+	return parseItems(content, 0)
+}
+
+func parseStrings(content []byte) (stringTable, error) {
+	items, err := parseItems(content, 4)
+	if err != nil {
+		return stringTable{}, err
+	}
+
+	start := uint64(len(items.data) + len(items.offsets))
+	return stringTable{
+		listTable: items,
+		sorted:    content[start : start+items.count*4],
+	}, nil
+}
+
+func parseDocuments(content []byte) (documentTable, error) {
+	if len(content) < 8 {
+		return documentTable{}, fmt.Errorf("too short")
+	}
+
+	count := uint64At(content, 0)
+	if count > uint64(len(content))/documentSize {
+		return documentTable{}, fmt.Errorf("invalid count %d", count)
+	}
+
+	end := 8 + count*documentSize
+	return documentTable{
+		records: content[8:end],
+		blob:    content[end:],
+		count:   count,
+	}, nil
+}
+
+func parseCentroids(content []byte) ([]byte, error) {
+	if len(content) < 8 {
+		return nil, fmt.Errorf("too short")
+	}
+
+	count := uint64(uint32At(content, 0))
+	dimension := uint64(uint32At(content, 4))
+	if count*dimension*8 != uint64(len(content)-8) {
+		return nil, fmt.Errorf("%d centroids of dimension %d don't fit in %d bytes", count, dimension, len(content))
+	}
+
+	return content, nil
+}
+
+// Returns item i or nil if its offsets are corrupt
+func (l *listTable) get(i int32) []byte {
+	if i < 0 || uint64(i) >= l.count {
+		return nil
+	}
+
+	start := uint64At(l.offsets, uint64(i)*8)
+	end := uint64At(l.offsets, uint64(i+1)*8)
+	if start > end || end > uint64(len(l.data)) {
+		return nil
+	}
+	return l.data[start:end]
+}
+
+func (s *stringTable) id(k uint64) int32 {
+	return int32(uint32At(s.sorted, k*4))
+}
+
+// Returns the position of the first string not less than word in sorted order
+func (s *stringTable) search(word []byte) uint64 {
+	return uint64(sort.Search(int(s.count), func(k int) bool {
+		return bytes.Compare(s.get(s.id(uint64(k))), word) >= 0
+	}))
+}
+
+func (s *stringTable) find(word []byte) int32 {
+	k := s.search(word)
+	if k < s.count && bytes.Equal(s.get(s.id(k)), word) {
+		return s.id(k)
+	}
+	return -1
+}
+
+// Returns the number of terms in the dictionary
+func (i *Index) NumTerms() int {
+	return int(i.dictionary.count)
+}
+
+// Returns the number of documents
+func (i *Index) Len() int {
+	return int(i.documents.count)
+}
+
+// Returns the number of document ids, which is Len since indices with deleted documents aren't written
+func (i *Index) NumDocuments() int {
+	return int(i.documents.count)
+}
+
+// Find returns the id of the term or -1 if it's not in the dictionary
+func (i *Index) Find(term []byte) int32 {
+	return i.dictionary.find(term)
+}
+
+func (i *Index) Term(termID int32) []byte {
+	return i.dictionary.get(termID)
+}
+
+// WalkPrefix calls operation for all the terms starting with prefix in lexicographic order
+func (i *Index) WalkPrefix(prefix []byte, operation func([]byte, int32)) {
+	for k := i.dictionary.search(prefix); k < i.dictionary.count; k++ {
+		termID := i.dictionary.id(k)
+		term := i.dictionary.get(termID)
+		if !bytes.HasPrefix(term, prefix) {
+			return
+		}
+		operation(term, termID)
+	}
+}
+
+// WalkPattern calls operation for all the terms matching the pattern in lexicographic order,
+// see trie.MatchPattern. Only the terms starting with the part before the first wildcard are checked
+func (i *Index) WalkPattern(pattern []byte, operation func([]byte, int32)) {
+	prefix := pattern
+	if wildcard := bytes.IndexAny(pattern, "*?"); wildcard != -1 {
+		prefix = pattern[:wildcard]
+	}
+
+	i.WalkPrefix(prefix, func(term []byte, termID int32) {
+		if trie.MatchPattern(pattern, term) {
+			operation(term, termID)
+		}
+	})
+}
+
+// WalkFuzzy calls operation for all the terms at most maxDistance edits away from the word
+// in lexicographic order. Unlike in a trie every term is compared with the word
+func (i *Index) WalkFuzzy(word []byte, maxDistance int, operation func([]byte, int32, int)) {
+	for k := uint64(0); k < i.dictionary.count; k++ {
+		termID := i.dictionary.id(k)
+		term := i.dictionary.get(termID)
+		if len(term) > len(word)+maxDistance || len(word) > len(term)+maxDistance {
+			continue
+		}
+		if distance := trie.EditDistance(word, term); distance <= maxDistance {
+			operation(term, termID, distance)
+		}
+	}
+}
+
+// FindClass returns the id of the class or -1 if there's no such class
+func (i *Index) FindClass(name []byte) int32 {
+	return i.classes.find(name)
+}
+
+func (i *Index) ClassName(classID int32) []byte {
+	return i.classes.get(classID)
+}
+
+func (i *Index) NumClasses() int {
+	return int(i.classes.count)
+}
+
+// Returns the number of documents containing the term
+func (i *Index) DocumentFrequency(termID int32) int {
+	return indices.NewPostingDecoder(i.inverse.get(termID)).Len()
+}
+
+// TermCursor returns a cursor over the documents containing the term
+func (i *Index) TermCursor(termID int32) *indices.Cursor {
+	return indices.NewEncodedCursor(i.inverse.get(termID))
+}
+
+// DocumentCursor returns a cursor over the terms in the document
+func (i *Index) DocumentCursor(docID int32) *indices.Cursor {
+	return indices.NewEncodedCursor(i.forward.get(docID))
+}
+
+// Document decodes the information about the document
+func (i *Index) Document(docID int32) (indices.DocumentInfo, error) {
+	if docID < 0 || uint64(docID) >= i.documents.count {
+		return indices.DocumentInfo{}, fmt.Errorf("no document %d", docID)
+	}
+
+	record := uint64(docID) * documentSize
+	r := i.documents.records
+	doc := indices.DocumentInfo{
+		Length:       int32(uint32At(r, record)),
+		UniqueLength: int32(uint32At(r, record+4)),
+		ClusterID:    int(int32(uint32At(r, record+8))),
+	}
+
+	nameOffset := uint64At(r, record+12)
+	nameLength := uint64(uint32At(r, record+20))
+	classesOffset := uint64At(r, record+24)
+	classesCount := uint64(uint32At(r, record+32))
+
+	// compared so that a corrupt offset can't overflow
+	blob := i.documents.blob
+	size := uint64(len(blob))
+	if nameOffset > size || nameLength > size-nameOffset || classesOffset > size || classesCount > (size-classesOffset)/4 {
+		return indices.DocumentInfo{}, fmt.Errorf("corrupt document %d", docID)
+	}
+
+	doc.Name = string(blob[nameOffset : nameOffset+nameLength])
+	doc.Classes = make([]int32, classesCount)
+	for c := range doc.Classes {
+		doc.Classes[c] = int32(uint32At(blob, classesOffset+uint64(c)*4))
+	}
+
+	return doc, nil
+}
+
+// DocumentLength returns the length of the document without decoding the rest of it
+func (i *Index) DocumentLength(docID int32) int32 {
+	if docID < 0 || uint64(docID) >= i.documents.count {
+		return 0
+	}
+	return int32(uint32At(i.documents.records, uint64(docID)*documentSize))
+}
+
+// Indices with deleted documents can't be written, see WriteFile
+func (i *Index) IsDeleted(docID int32) bool {
+	return false
+}
+
+// Centroids decodes the cluster centroids, if there are any
+func (i *Index) Centroids() [][]float64 {
+	if i.centroids == nil {
+		return nil
+	}
+
+	count := uint32At(i.centroids, 0)
+	dimension := uint64(uint32At(i.centroids, 4))
+	if count == 0 {
+		return nil
+	}
+
+	centroids := make([][]float64, count)
+	offset := uint64(8)
+	for c := range centroids {
+		centroids[c] = make([]float64, dimension)
+		for d := range centroids[c] {
+			centroids[c][d] = math.Float64frombits(uint64At(i.centroids, offset))
+			offset += 8
+		}
+	}
+	return centroids
+}
+
+// Metadata decodes the metadata of the index, which is empty if the file has none
+func (i *Index) Metadata() (indices.Metadata, error) {
+	var metadata indices.Metadata
+	if i.metadata == nil {
+		return metadata, nil
+	}
+
+	err := gob.NewDecoder(bytes.NewReader(i.metadata)).Decode(&metadata)
+	if err != nil {
+		return metadata, fmt.Errorf("unable to decode metadata: %s", err)
+	}
+	return metadata, nil
+}
+
+// Load copies the whole index into memory.
+// The posting lists are left encoded and the score bounds for ranking aren't kept in the file.
+func (i *Index) Load() (*indices.TotalIndex, error) {
+	ti := indices.NewTotalIndex()
+
+	for termID := int32(0); termID < int32(i.dictionary.count); termID++ {
+		if id := ti.Dictionary.Get(i.Term(termID)); id != termID {
+			return nil, fmt.Errorf("term %d is a duplicate of %d", termID, id)
+		}
+	}
+
+	for classID := int32(0); classID < int32(i.classes.count); classID++ {
+		if id := ti.ClassNames.Get(i.ClassName(classID)); id != classID {
+			return nil, fmt.Errorf("class %d is a duplicate of %d", classID, id)
+		}
+	}
+
+	ti.Inverse = loadLists(&i.inverse)
+	ti.Forward = loadLists(&i.forward)
+
+	ti.Documents = make([]indices.DocumentInfo, i.documents.count)
+	for docID := range ti.Documents {
+		var err error
+		ti.Documents[docID], err = i.Document(int32(docID))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ti.Centroids = i.Centroids()
+
+	var err error
+	ti.Metadata, err = i.Metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	return ti, nil
+}
+
+func loadLists(l *listTable) indices.Index {
+	index := indices.Index{
+		PostingLists: make([]indices.PostingList, l.count),
+		Encoded:      make([][]byte, l.count),
+	}
+
+	for listID := range index.PostingLists {
+		index.Encoded[listID] = append([]byte(nil), l.get(int32(listID))...)
+		index.PostingLists[listID] = indices.PostingList{
+			FirstIndex: -1,
+			LastIndex:  -1,
+			Len:        indices.NewPostingDecoder(index.Encoded[listID]).Len(),
+		}
+	}
+
+	return index
+}
@@ -0,0 +1,397 @@
+package diskindex
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/bitterfly/search/indices"
+)
+
+// Writer writes the sections of an index file one after the other,
+// so posting lists can be streamed into it without keeping them in memory.
+// The header is written on Close.
+type Writer struct {
+	file     *os.File
+	buffer   *bufio.Writer
+	offset   uint64
+	sections []section
+	scratch  [8]byte
+}
+
+func Create(filename string) (*Writer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create file: %s", err)
+	}
+
+	w := &Writer{
+		file:   file,
+		buffer: bufio.NewWriterSize(file, 1<<20),
+	}
+
+	// room for the header
+	err = w.write(make([]byte, tableEnd))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Writer) write(data []byte) error {
+	_, err := w.buffer.Write(data)
+	if err != nil {
+		return fmt.Errorf("unable to write: %s", err)
+	}
+	w.offset += uint64(len(data))
+	return nil
+}
+
+func (w *Writer) writeUint64(x uint64) error {
+	binary.LittleEndian.PutUint64(w.scratch[:], x)
+	return w.write(w.scratch[:8])
+}
+
+func (w *Writer) writeUint32(x uint32) error {
+	binary.LittleEndian.PutUint32(w.scratch[:], x)
+	return w.write(w.scratch[:4])
+}
+
+func (w *Writer) begin(kind SectionKind) (uint64, error) {
+	if len(w.sections) == maxSections {
+		return 0, fmt.Errorf("too many sections")
+	}
+	for _, s := range w.sections {
+		if s.kind == kind {
+			return 0, fmt.Errorf("section %s is already written", kind)
+		}
+	}
+	return w.offset, nil
+}
+
+func (w *Writer) end(kind SectionKind, start uint64) {
+	w.sections = append(w.sections, section{kind: kind, offset: start, length: w.offset - start})
+}
+
+// Writes the items returned by next and their offsets, which are relative to the start of the section
+func (w *Writer) writeItems(next func() ([]byte, bool)) ([]uint64, error) {
+	start := w.offset
+	offsets := []uint64{0}
+	for {
+		item, ok := next()
+		if !ok {
+			break
+		}
+		err := w.write(item)
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, w.offset-start)
+	}
+
+	for _, offset := range offsets {
+		err := w.writeUint64(offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return offsets, nil
+}
+
+// WriteLists writes a lists section with the encoded posting lists returned by next,
+// in order of list id, until it returns false
+func (w *Writer) WriteLists(kind SectionKind, next func() ([]byte, bool)) error {
+	start, err := w.begin(kind)
+	if err != nil {
+		return err
+	}
+
+	offsets, err := w.writeItems(next)
+	if err != nil {
+		return err
+	}
+
+	err = w.writeUint64(uint64(len(offsets) - 1))
+	if err != nil {
+		return err
+	}
+
+	w.end(kind, start)
+	return nil
+}
+
+// WriteStrings writes a strings section, strings[i] is the string with id i
+func (w *Writer) WriteStrings(kind SectionKind, strings [][]byte) error {
+	start, err := w.begin(kind)
+	if err != nil {
+		return err
+	}
+
+	i := 0
+	_, err = w.writeItems(func() ([]byte, bool) {
+		if i == len(strings) {
+			return nil, false
+		}
+		i++
+		return strings[i-1], true
+	})
+	if err != nil {
+		return err
+	}
+
+	sorted := make([]uint32, len(strings))
+	for i := range sorted {
+		sorted[i] = uint32(i)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(strings[sorted[i]], strings[sorted[j]]) < 0
+	})
+
+	for _, id := range sorted {
+		err = w.writeUint32(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.writeUint64(uint64(len(strings)))
+	if err != nil {
+		return err
+	}
+
+	w.end(kind, start)
+	return nil
+}
+
+func (w *Writer) WriteDocuments(documents []indices.DocumentInfo) error {
+	start, err := w.begin(SectionDocuments)
+	if err != nil {
+		return err
+	}
+
+	err = w.writeUint64(uint64(len(documents)))
+	if err != nil {
+		return err
+	}
+
+	var blob []byte
+	for i := range documents {
+		doc := &documents[i]
+
+		record := make([]byte, 0, documentSize)
+		record = appendUint32(record, uint32(doc.Length))
+		record = appendUint32(record, uint32(doc.UniqueLength))
+		record = appendUint32(record, uint32(doc.ClusterID))
+		record = appendUint64(record, uint64(len(blob)))
+		record = appendUint32(record, uint32(len(doc.Name)))
+		blob = append(blob, doc.Name...)
+		record = appendUint64(record, uint64(len(blob)))
+		record = appendUint32(record, uint32(len(doc.Classes)))
+		for _, class := range doc.Classes {
+			blob = appendUint32(blob, uint32(class))
+		}
+
+		err = w.write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = w.write(blob)
+	if err != nil {
+		return err
+	}
+
+	w.end(SectionDocuments, start)
+	return nil
+}
+
+func (w *Writer) WriteCentroids(centroids [][]float64) error {
+	start, err := w.begin(SectionCentroids)
+	if err != nil {
+		return err
+	}
+
+	dimension := 0
+	if len(centroids) > 0 {
+		dimension = len(centroids[0])
+	}
+
+	err = w.writeUint32(uint32(len(centroids)))
+	if err != nil {
+		return err
+	}
+	err = w.writeUint32(uint32(dimension))
+	if err != nil {
+		return err
+	}
+
+	for i := range centroids {
+		if len(centroids[i]) != dimension {
+			return fmt.Errorf("centroid %d has dimension %d instead of %d", i, len(centroids[i]), dimension)
+		}
+		for _, x := range centroids[i] {
+			err = w.writeUint64(math.Float64bits(x))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	w.end(SectionCentroids, start)
+	return nil
+}
+
+func (w *Writer) WriteMetadata(metadata indices.Metadata) error {
+	start, err := w.begin(SectionMetadata)
+	if err != nil {
+		return err
+	}
+
+	var encoded bytes.Buffer
+	err = gob.NewEncoder(&encoded).Encode(&metadata)
+	if err != nil {
+		return fmt.Errorf("unable to encode metadata: %s", err)
+	}
+
+	err = w.write(encoded.Bytes())
+	if err != nil {
+		return err
+	}
+
+	w.end(SectionMetadata, start)
+	return nil
+}
+
+// Abort closes and removes the file after a failed write, so that no file with a valid header
+// and missing sections is left behind
+func (w *Writer) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// Close writes the header and closes the file, which is removed if that fails
+func (w *Writer) Close() error {
+	err := w.buffer.Flush()
+	if err != nil {
+		w.Abort()
+		return fmt.Errorf("unable to write: %s", err)
+	}
+
+	header := make([]byte, 0, tableEnd)
+	header = append(header, magic[:]...)
+	header = appendUint32(header, Version)
+	header = appendUint32(header, uint32(len(w.sections)))
+	for _, s := range w.sections {
+		header = appendUint32(header, uint32(s.kind))
+		header = appendUint32(header, 0)
+		header = appendUint64(header, s.offset)
+		header = appendUint64(header, s.length)
+	}
+
+	_, err = w.file.WriteAt(header, 0)
+	if err != nil {
+		w.Abort()
+		return fmt.Errorf("unable to write header: %s", err)
+	}
+
+	return w.file.Close()
+}
+
+// WriteFile writes the whole index, which can't have deleted documents, see indices.TotalIndex.Compact
+func WriteFile(filename string, index *indices.TotalIndex) error {
+	if index.NumDeleted != 0 {
+		return fmt.Errorf("index has %d deleted documents, it has to be compacted first", index.NumDeleted)
+	}
+
+	w, err := Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = writeIndex(w, index)
+	if err != nil {
+		w.Abort()
+		return err
+	}
+
+	return w.Close()
+}
+
+func writeIndex(w *Writer, index *indices.TotalIndex) error {
+	err := w.WriteMetadata(index.Metadata)
+	if err != nil {
+		return fmt.Errorf("unable to write metadata: %s", err)
+	}
+
+	err = w.WriteStrings(SectionDictionary, dictionaryStrings(index.Dictionary.Inverse, index.Dictionary.Size))
+	if err != nil {
+		return fmt.Errorf("unable to write dictionary: %s", err)
+	}
+
+	err = w.WriteStrings(SectionClasses, dictionaryStrings(index.ClassNames.Inverse, index.ClassNames.Size))
+	if err != nil {
+		return fmt.Errorf("unable to write classes: %s", err)
+	}
+
+	err = w.WriteLists(SectionInverse, listsOf(&index.Inverse))
+	if err != nil {
+		return fmt.Errorf("unable to write inverse index: %s", err)
+	}
+
+	err = w.WriteLists(SectionForward, listsOf(&index.Forward))
+	if err != nil {
+		return fmt.Errorf("unable to write forward index: %s", err)
+	}
+
+	err = w.WriteDocuments(index.Documents)
+	if err != nil {
+		return fmt.Errorf("unable to write documents: %s", err)
+	}
+
+	err = w.WriteCentroids(index.Centroids)
+	if err != nil {
+		return fmt.Errorf("unable to write centroids: %s", err)
+	}
+
+	return nil
+}
+
+func dictionaryStrings(inverse map[int32][]byte, size int32) [][]byte {
+	strings := make([][]byte, size)
+	for id := range strings {
+		strings[id] = inverse[int32(id)]
+	}
+	return strings
+}
+
+func listsOf(index *indices.Index) func() ([]byte, bool) {
+	listID := 0
+	return func() ([]byte, bool) {
+		if listID == len(index.PostingLists) {
+			return nil, false
+		}
+		listID++
+		return index.EncodeList(int32(listID - 1)), true
+	}
+}
+
+func appendUint64(data []byte, x uint64) []byte {
+	var scratch [8]byte
+	binary.LittleEndian.PutUint64(scratch[:], x)
+	return append(data, scratch[:]...)
+}
+
+func appendUint32(data []byte, x uint32) []byte {
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], x)
+	return append(data, scratch[:]...)
+}
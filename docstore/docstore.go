@@ -0,0 +1,200 @@
+package docstore
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/bitterfly/search/indices"
+)
+
+// Document holds the stored fields of an indexed document
+type Document struct {
+	Title   string
+	Body    string
+	Date    string
+	Classes []string
+}
+
+// Store keeps the documents of an index by document id.
+// Every document is compressed on its own, so getting one doesn't decompress the others
+type Store struct {
+	Records [][]byte
+}
+
+func New() *Store {
+	return &Store{}
+}
+
+func (s *Store) Len() int {
+	return len(s.Records)
+}
+
+func (s *Store) Put(docID int32, doc *Document) error {
+	record, err := encode(doc)
+	if err != nil {
+		return fmt.Errorf("unable to compress document %d: %s", docID, err)
+	}
+
+	for int(docID) >= len(s.Records) {
+		s.Records = append(s.Records, nil)
+	}
+	s.Records[docID] = record
+
+	return nil
+}
+
+// Add stores the fields passed along with a document which was added to the index with docID,
+// it can be given to TotalIndex.AddManyWith
+func (s *Store) Add(docID int32, d *indices.InfoAndTerms) error {
+	return s.Put(docID, &Document{
+		Title:   d.Name,
+		Body:    d.Body,
+		Date:    d.Date,
+		Classes: d.Classes,
+	})
+}
+
+func (s *Store) Get(docID int32) (*Document, error) {
+	if docID < 0 || int(docID) >= len(s.Records) || s.Records[docID] == nil {
+		return nil, fmt.Errorf("no stored document with id %d", docID)
+	}
+
+	doc, err := decode(s.Records[docID])
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress document %d: %s", docID, err)
+	}
+	return doc, nil
+}
+
+// Renumber moves the documents to the ids returned by TotalIndex.Compact,
+// so that the store matches the compacted index. Documents with id -1 are dropped
+func (s *Store) Renumber(docIDs []int32) {
+	var records [][]byte
+	for docID, record := range s.Records {
+		if docID >= len(docIDs) || docIDs[docID] == -1 || record == nil {
+			continue
+		}
+
+		newID := int(docIDs[docID])
+		for newID >= len(records) {
+			records = append(records, nil)
+		}
+		records[newID] = record
+	}
+	s.Records = records
+}
+
+func (s *Store) SerialiseTo(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(s)
+}
+
+func (s *Store) SerialiseToFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("unable to open file: %s", err)
+	}
+	defer f.Close()
+
+	return s.SerialiseTo(f)
+}
+
+func (s *Store) DeserialiseFrom(r io.Reader) error {
+	return gob.NewDecoder(r).Decode(s)
+}
+
+func (s *Store) DeserialiseFromFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("unable to open file: %s", err)
+	}
+	defer f.Close()
+
+	return s.DeserialiseFrom(f)
+}
+
+// A record is the deflated sequence of the length prefixed fields
+func encode(doc *Document) ([]byte, error) {
+	var raw bytes.Buffer
+	writeString(&raw, doc.Title)
+	writeString(&raw, doc.Date)
+	writeString(&raw, doc.Body)
+	writeUvarint(&raw, uint64(len(doc.Classes)))
+	for _, class := range doc.Classes {
+		writeString(&raw, class)
+	}
+
+	var compressed bytes.Buffer
+	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(raw.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
+
+func decode(record []byte) (*Document, error) {
+	raw, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(record)))
+	if err != nil {
+		return nil, err
+	}
+
+	r := bytes.NewReader(raw)
+	doc := &Document{}
+
+	for _, field := range []*string{&doc.Title, &doc.Date, &doc.Body} {
+		if *field, err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+
+	numClasses, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	doc.Classes = make([]string, numClasses)
+	for i := range doc.Classes {
+		if doc.Classes[i], err = readString(r); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func writeUvarint(buffer *bytes.Buffer, x uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	buffer.Write(scratch[:binary.PutUvarint(scratch[:], x)])
+}
+
+func writeString(buffer *bytes.Buffer, s string) {
+	writeUvarint(buffer, uint64(len(s)))
+	buffer.WriteString(s)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if length > uint64(r.Len()) {
+		return "", fmt.Errorf("field of length %d is longer than the rest of the record", length)
+	}
+
+	s := make([]byte, length)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
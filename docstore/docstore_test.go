@@ -0,0 +1,86 @@
+package docstore
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	assert := assert.New(t)
+
+	store := New()
+
+	doc := &Document{
+		Title:   "OIL PRICES RISE",
+		Body:    "Oil prices rose sharply today, traders said.",
+		Date:    "26-FEB-1987 15:01:01.79",
+		Classes: []string{"crude", "nat-gas"},
+	}
+	assert.Nil(store.Put(1, doc))
+
+	info := indices.NewInfoAndTerms()
+	info.Name = "GOLD"
+	info.Body = "Gold fell."
+	assert.Nil(store.Add(0, info))
+
+	assert.Equal(2, store.Len())
+
+	stored, err := store.Get(1)
+	assert.Nil(err)
+	assert.Equal(doc, stored)
+
+	stored, err = store.Get(0)
+	assert.Nil(err)
+	assert.Equal("GOLD", stored.Title)
+	assert.Equal("Gold fell.", stored.Body)
+	assert.Empty(stored.Classes)
+
+	_, err = store.Get(2)
+	assert.NotNil(err)
+
+	var buffer bytes.Buffer
+	assert.Nil(store.SerialiseTo(&buffer))
+
+	loaded := New()
+	assert.Nil(loaded.DeserialiseFrom(&buffer))
+
+	stored, err = loaded.Get(1)
+	assert.Nil(err)
+	assert.Equal(doc, stored)
+}
+
+func TestStore_Gaps(t *testing.T) {
+	store := New()
+	assert.Nil(t, store.Put(2, &Document{Title: "foo"}))
+
+	_, err := store.Get(1)
+	assert.NotNil(t, err)
+}
+
+func TestStore_Renumber(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := indices.NewTotalIndex()
+	store := New()
+	for _, name := range []string{"foo", "bar", "qux"} {
+		info := indices.NewInfoAndTerms()
+		info.Name = name
+		info.AddTerm(name)
+		assert.Nil(store.Add(ti.Add(info), info))
+	}
+
+	assert.Nil(ti.Delete(1))
+	docIDs, _ := ti.Compact()
+	store.Renumber(docIDs)
+
+	assert.Equal(2, store.Len())
+	for docID, name := range []string{"foo", "qux"} {
+		stored, err := store.Get(int32(docID))
+		assert.Nil(err)
+		assert.Equal(name, stored.Title)
+		assert.Equal(name, ti.Documents[docID].Name)
+	}
+}
@@ -0,0 +1,186 @@
+package docstore
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/bitterfly/search/processing"
+)
+
+const (
+	DefaultWindow = 30
+	DefaultBefore = "<b>"
+	DefaultAfter  = "</b>"
+)
+
+// Snippeter picks the window of a document's body which contains the most query terms
+// and surrounds the matching words with Before and After.
+// The snippet is HTML: the text of the body is escaped, Before and After aren't
+type Snippeter struct {
+	Window int
+	Before string
+	After  string
+
+	tokeniser processing.Tokeniser
+}
+
+type word struct {
+	start int
+	end   int
+
+	// the query term the word normalises to or "" if it isn't one
+	term string
+}
+
+func NewSnippeter(tokeniser processing.Tokeniser) *Snippeter {
+	return &Snippeter{
+		Window:    DefaultWindow,
+		Before:    DefaultBefore,
+		After:     DefaultAfter,
+		tokeniser: tokeniser,
+	}
+}
+
+// Snippet returns Window words of the body, preferring the ones with the most
+// distinct query terms, then with the most occurrences of them and then the ones
+// with the matches closest to the middle.
+// Words are compared to the query after normalising both with the tokeniser
+func (s *Snippeter) Snippet(body string, query string) string {
+	terms := make(map[string]struct{})
+	s.tokeniser.GetTerms(query, func(term string) {
+		terms[term] = struct{}{}
+	})
+
+	words := s.words(body, terms)
+	if len(words) == 0 {
+		return ""
+	}
+
+	window := s.Window
+	if window <= 0 || window > len(words) {
+		window = len(words)
+	}
+
+	best := 0
+	bestDistinct, bestTotal, bestBalance := -1, -1, 0
+	for start := 0; start+window <= len(words); start++ {
+		distinct, total, balance := score(words[start : start+window])
+		better := distinct > bestDistinct ||
+			(distinct == bestDistinct && total > bestTotal) ||
+			(distinct == bestDistinct && total == bestTotal && balance < bestBalance)
+
+		if better {
+			best, bestDistinct, bestTotal, bestBalance = start, distinct, total, balance
+		}
+	}
+
+	return s.highlight(body, words, best, best+window)
+}
+
+// Splits the body into words (letters, digits and inner hyphens) and marks
+// the ones whose normalised form is a query term
+func (s *Snippeter) words(body string, terms map[string]struct{}) []word {
+	var words []word
+
+	start := -1
+	for i, symbol := range body + " " {
+		isWordSymbol := unicode.IsLetter(symbol) || unicode.IsDigit(symbol) || (symbol == '-' && start != -1)
+		if isWordSymbol {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+
+		if start != -1 {
+			text := strings.TrimRight(body[start:i], "-")
+			w := word{start: start, end: start + len(text)}
+			if term := s.tokeniser.Normalise(text); term != "" {
+				if _, ok := terms[term]; ok {
+					w.term = term
+				}
+			}
+			words = append(words, w)
+			start = -1
+		}
+	}
+
+	return words
+}
+
+// Returns the number of distinct query terms in the window, the number of their occurrences
+// and how far from the middle of the window they are
+func score(window []word) (int, int, int) {
+	seen := make(map[string]struct{})
+	total := 0
+	first, last := -1, -1
+
+	for i, w := range window {
+		if w.term != "" {
+			seen[w.term] = struct{}{}
+			total++
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	if first == -1 {
+		return 0, 0, 0
+	}
+
+	balance := first - (len(window) - 1 - last)
+	if balance < 0 {
+		balance = -balance
+	}
+	return len(seen), total, balance
+}
+
+// Writes the words from first to last (excluding) with their original punctuation,
+// but with all whitespace collapsed into single spaces. The text is escaped before
+// the highlighting is added
+func (s *Snippeter) highlight(body string, words []word, first, last int) string {
+	var snippet strings.Builder
+
+	if first > 0 {
+		snippet.WriteString("... ")
+	}
+
+	for i := first; i < last; i++ {
+		if i > first {
+			snippet.WriteString(html.EscapeString(collapseSpaces(body[words[i-1].end:words[i].start])))
+		}
+
+		if words[i].term != "" {
+			snippet.WriteString(s.Before)
+			snippet.WriteString(html.EscapeString(body[words[i].start:words[i].end]))
+			snippet.WriteString(s.After)
+		} else {
+			snippet.WriteString(html.EscapeString(body[words[i].start:words[i].end]))
+		}
+	}
+
+	if last < len(words) {
+		snippet.WriteString(" ...")
+	}
+
+	return snippet.String()
+}
+
+func collapseSpaces(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return " "
+	}
+
+	joined := strings.Join(fields, " ")
+	if unicode.IsSpace(rune(text[0])) {
+		joined = " " + joined
+	}
+	if unicode.IsSpace(rune(text[len(text)-1])) {
+		joined += " "
+	}
+	return joined
+}
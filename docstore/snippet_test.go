@@ -0,0 +1,74 @@
+package docstore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testTokeniser struct{}
+
+func (tt *testTokeniser) Tokenise(text string) []string { return strings.Fields(text) }
+func (tt *testTokeniser) Normalise(token string) string {
+	return strings.TrimSuffix(strings.ToLower(token), "s")
+}
+func (tt *testTokeniser) IsStopWord(word string) bool { return word == "and" }
+func (tt *testTokeniser) GetTerms(text string, operation func(string)) {
+	for _, token := range tt.Tokenise(text) {
+		if term := tt.Normalise(token); !tt.IsStopWord(term) {
+			operation(term)
+		}
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewSnippeter(&testTokeniser{})
+	s.Window = 5
+	s.Before = "["
+	s.After = "]"
+
+	body := "The bank said on Monday that\n  interest rates will rise, while oil prices fell and rates of other banks stayed the same."
+
+	assert.Equal(
+		"... Monday that [interest] [rates] will ...",
+		s.Snippet(body, "interest rate"),
+	)
+
+	// the window with both terms wins over the one with more occurrences of one
+	assert.Equal(
+		"... [rates] will rise, while [oil] ...",
+		s.Snippet(body, "oil and rates"),
+	)
+	assert.Equal(
+		"... that interest [rates] will rise ...",
+		s.Snippet(body, "rates"),
+	)
+
+	assert.Equal("The bank said on Monday ...", s.Snippet(body, "gold"))
+
+	s.Window = 100
+	assert.Equal(
+		"The [bank] said on Monday that interest rates will rise, while oil prices fell and rates of other [banks] stayed the same",
+		s.Snippet(body, "BANK"),
+	)
+}
+
+func TestSnippet_Whitespace(t *testing.T) {
+	s := NewSnippeter(&testTokeniser{})
+
+	assert.Equal(t, "<b>Oil</b> prices, rose", s.Snippet("  Oil   prices,\n\t rose  ", "oil"))
+	assert.Equal(t, "", s.Snippet(" ... ", "oil"))
+}
+
+func TestSnippet_Escape(t *testing.T) {
+	s := NewSnippeter(&testTokeniser{})
+
+	assert.Equal(
+		t,
+		"script&gt;<b>oil</b>&lt;/script&gt; &amp; &#34;gold",
+		s.Snippet("<script>oil</script> & \"gold\"", "oil"),
+	)
+}
@@ -0,0 +1,238 @@
+// Package formats converts indices from and to formats other tools can read.
+//
+// The documents are exported as JSON Lines, one object per document with its classes and term counts,
+// or as a sparse document × term matrix in the SVMlight or the Matrix Market format. The matrices
+// refer to the terms and classes by id, which are listed in TSV files written by WriteVocabulary
+// and WriteClasses. All the ids in the exported files start from 1: they are the ids in the index plus one.
+// The rows of the matrices are the documents which aren't deleted, in order, and WriteDocuments
+// lists their names and classes for the Matrix Market format, which has no place for them.
+package formats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bitterfly/search/indices"
+)
+
+// Weighting is the value of a term in a document in the exported matrices
+type Weighting int
+
+const (
+	// The number of times the term is in the document
+	TF Weighting = iota
+	// TF times the IDF of the term, see TotalIndex.IDF
+	TFIDF
+)
+
+func ParseWeighting(name string) (Weighting, error) {
+	switch name {
+	case "tf":
+		return TF, nil
+	case "tfidf":
+		return TFIDF, nil
+	default:
+		return TF, fmt.Errorf("unknown weighting %s, it has to be tf or tfidf", name)
+	}
+}
+
+func (w Weighting) String() string {
+	if w == TFIDF {
+		return "tfidf"
+	}
+	return "tf"
+}
+
+func (w Weighting) weight(ti *indices.TotalIndex, posting *indices.Posting) float64 {
+	if w == TFIDF {
+		return float64(posting.Count) * ti.IDF(posting.Index)
+	}
+	return float64(posting.Count)
+}
+
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
+
+// Tabs and new lines would break the TSV files
+func cleanField(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+type jsonDocument struct {
+	ID      int32            `json:"id"`
+	Name    string           `json:"name"`
+	Classes []string         `json:"classes"`
+	Length  int32            `json:"length"`
+	Cluster int              `json:"cluster"`
+	Terms   map[string]int32 `json:"terms"`
+}
+
+// WriteJSONLines writes every document which isn't deleted as a JSON object on its own line
+func WriteJSONLines(w io.Writer, ti *indices.TotalIndex) error {
+	buffer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffer)
+
+	for docID, doc := range ti.Documents {
+		if doc.Deleted {
+			continue
+		}
+
+		record := jsonDocument{
+			ID:      int32(docID),
+			Name:    doc.Name,
+			Classes: make([]string, len(doc.Classes)),
+			Length:  doc.Length,
+			Cluster: doc.ClusterID,
+			Terms:   make(map[string]int32),
+		}
+		for i, class := range doc.Classes {
+			record.Classes[i] = string(ti.ClassNames.GetInverse(class))
+		}
+		ti.LoopOverDocumentPostings(int32(docID), func(posting *indices.Posting) {
+			record.Terms[string(ti.Dictionary.GetInverse(posting.Index))] = posting.Count
+		})
+
+		err := encoder.Encode(&record)
+		if err != nil {
+			return fmt.Errorf("unable to write document %d: %s", docID, err)
+		}
+	}
+
+	return buffer.Flush()
+}
+
+// WriteSVMlight writes a line for every document: the comma separated ids of its classes
+// (0 if it has none, since SVMlight tools expect a label), the term id:weight pairs
+// and the name of the document in a comment
+func WriteSVMlight(w io.Writer, ti *indices.TotalIndex, weighting Weighting) error {
+	buffer := bufio.NewWriter(w)
+
+	for docID, doc := range ti.Documents {
+		if doc.Deleted {
+			continue
+		}
+
+		line := []string{"0"}
+		if len(doc.Classes) != 0 {
+			classes := make([]string, len(doc.Classes))
+			for i, class := range doc.Classes {
+				classes[i] = strconv.Itoa(int(class) + 1)
+			}
+			line[0] = strings.Join(classes, ",")
+		}
+
+		ti.LoopOverDocumentPostings(int32(docID), func(posting *indices.Posting) {
+			line = append(line, fmt.Sprintf("%d:%s", posting.Index+1, formatFloat(weighting.weight(ti, posting))))
+		})
+
+		_, err := fmt.Fprintf(buffer, "%s # %s\n", strings.Join(line, " "), strings.Replace(doc.Name, "\n", " ", -1))
+		if err != nil {
+			return fmt.Errorf("unable to write document %d: %s", docID, err)
+		}
+	}
+
+	return buffer.Flush()
+}
+
+// WriteMatrixMarket writes the documents as a coordinate matrix with a row for every document
+// and a column for every term
+func WriteMatrixMarket(w io.Writer, ti *indices.TotalIndex, weighting Weighting) error {
+	buffer := bufio.NewWriter(w)
+
+	entries := 0
+	for docID, doc := range ti.Documents {
+		if !doc.Deleted {
+			entries += ti.Forward.PostingLists[docID].Len
+		}
+	}
+
+	fmt.Fprintf(buffer, "%%%%MatrixMarket matrix coordinate real general\n")
+	fmt.Fprintf(buffer, "%% documents x terms, %s weights\n", weighting)
+	fmt.Fprintf(buffer, "%d %d %d\n", ti.Len(), len(ti.Inverse.PostingLists), entries)
+
+	row := 0
+	for docID, doc := range ti.Documents {
+		if doc.Deleted {
+			continue
+		}
+		row++
+
+		var err error
+		ti.LoopOverDocumentPostings(int32(docID), func(posting *indices.Posting) {
+			if err == nil {
+				_, err = fmt.Fprintf(buffer, "%d %d %s\n", row, posting.Index+1, formatFloat(weighting.weight(ti, posting)))
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("unable to write document %d: %s", docID, err)
+		}
+	}
+
+	return buffer.Flush()
+}
+
+// WriteDocuments writes a TSV line for every matrix row: its number, the name of the document
+// and the comma separated ids of its classes
+func WriteDocuments(w io.Writer, ti *indices.TotalIndex) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "row\tname\tclasses\n")
+
+	row := 0
+	for _, doc := range ti.Documents {
+		if doc.Deleted {
+			continue
+		}
+		row++
+
+		classes := make([]string, len(doc.Classes))
+		for i, class := range doc.Classes {
+			classes[i] = strconv.Itoa(int(class) + 1)
+		}
+		fmt.Fprintf(buffer, "%d\t%s\t%s\n", row, cleanField(doc.Name), strings.Join(classes, ","))
+	}
+
+	return buffer.Flush()
+}
+
+// WriteVocabulary writes a TSV line for every term: its id, the term, the number of documents
+// it's in and the number of times it's in the collection
+func WriteVocabulary(w io.Writer, ti *indices.TotalIndex) error {
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "id\tterm\tdf\tcf\n")
+
+	for termID := int32(0); termID < int32(len(ti.Inverse.PostingLists)); termID++ {
+		df, cf := 0, 0
+		ti.LoopOverTermPostings(termID, func(posting *indices.Posting) {
+			df++
+			cf += int(posting.Count)
+		})
+		fmt.Fprintf(buffer, "%d\t%s\t%d\t%d\n", termID+1, cleanField(string(ti.Dictionary.GetInverse(termID))), df, cf)
+	}
+
+	return buffer.Flush()
+}
+
+// WriteClasses writes a TSV line for every class: its id, its name and the number of documents in it
+func WriteClasses(w io.Writer, ti *indices.TotalIndex) error {
+	counts := make([]int, ti.ClassNames.Size)
+	for _, doc := range ti.Documents {
+		if !doc.Deleted {
+			for _, class := range doc.Classes {
+				counts[class]++
+			}
+		}
+	}
+
+	buffer := bufio.NewWriter(w)
+	fmt.Fprintf(buffer, "id\tname\tdocuments\n")
+	for classID, count := range counts {
+		fmt.Fprintf(buffer, "%d\t%s\t%d\n", classID+1, cleanField(string(ti.ClassNames.GetInverse(int32(classID)))), count)
+	}
+
+	return buffer.Flush()
+}
@@ -0,0 +1,96 @@
+package formats
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeDocument(name string, text string, classes ...string) *indices.InfoAndTerms {
+	doc := indices.NewInfoAndTerms()
+	doc.Name = name
+	for _, term := range strings.Fields(text) {
+		doc.AddTerm(term)
+	}
+	doc.Classes = classes
+	return doc
+}
+
+func makeIndex() *indices.TotalIndex {
+	ti := indices.NewTotalIndex()
+	ti.Add(makeDocument("first", "foo bar foo", "sports"))
+	ti.Add(makeDocument("deleted", "bar baz", "politics"))
+	ti.Add(makeDocument("second", "qux bar", "politics", "sports"))
+	ti.Add(makeDocument("third", "foo"))
+	ti.Finalise()
+	ti.Delete(1)
+	return ti
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	assert := assert.New(t)
+
+	var output bytes.Buffer
+	assert.Nil(WriteJSONLines(&output, makeIndex()))
+	assert.Equal(
+		`{"id":0,"name":"first","classes":["sports"],"length":3,"cluster":-1,"terms":{"bar":1,"foo":2}}
+{"id":2,"name":"second","classes":["politics","sports"],"length":2,"cluster":-1,"terms":{"bar":1,"qux":1}}
+{"id":3,"name":"third","classes":[],"length":1,"cluster":-1,"terms":{"foo":1}}
+`,
+		output.String(),
+	)
+}
+
+func TestWriteSVMlight(t *testing.T) {
+	assert := assert.New(t)
+
+	var output bytes.Buffer
+	assert.Nil(WriteSVMlight(&output, makeIndex(), TF))
+	assert.Equal("1 1:2 2:1 # first\n2,1 2:1 4:1 # second\n0 1:1 # third\n", output.String())
+
+	ti := makeIndex()
+	output.Reset()
+	assert.Nil(WriteSVMlight(&output, ti, TFIDF))
+	idf := math.Log(3.0 / 3.0)
+	assert.Equal("0 1:"+formatFloat(idf)+" # third", strings.Split(output.String(), "\n")[2])
+}
+
+func TestWriteMatrixMarket(t *testing.T) {
+	assert := assert.New(t)
+
+	var output bytes.Buffer
+	assert.Nil(WriteMatrixMarket(&output, makeIndex(), TF))
+	assert.Equal(
+		`%%MatrixMarket matrix coordinate real general
+% documents x terms, tf weights
+3 4 5
+1 1 2
+1 2 1
+2 2 1
+2 4 1
+3 1 1
+`,
+		output.String(),
+	)
+}
+
+func TestWriteTables(t *testing.T) {
+	assert := assert.New(t)
+	ti := makeIndex()
+
+	var output bytes.Buffer
+	assert.Nil(WriteDocuments(&output, ti))
+	assert.Equal("row\tname\tclasses\n1\tfirst\t1\n2\tsecond\t2,1\n3\tthird\t\n", output.String())
+
+	output.Reset()
+	assert.Nil(WriteVocabulary(&output, ti))
+	assert.Equal("id\tterm\tdf\tcf\n1\tfoo\t2\t3\n2\tbar\t2\t2\n3\tbaz\t0\t0\n4\tqux\t1\t1\n", output.String())
+
+	output.Reset()
+	assert.Nil(WriteClasses(&output, ti))
+	assert.Equal("id\tname\tdocuments\n1\tsports\t2\n2\tpolitics\t1\n", output.String())
+}
@@ -0,0 +1,343 @@
+package formats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bitterfly/search/indices"
+)
+
+// ReadVocabulary reads the terms in a TSV file like the one written by WriteVocabulary.
+// Only the first two columns, the id and the term, are used and the header is optional.
+// A file with just one term per line is read too, the terms are then numbered from 1.
+// The term with id i is at i-1 in the returned slice
+func ReadVocabulary(r io.Reader) ([]string, error) {
+	return readNames(r, "term")
+}
+
+// ReadClasses reads the class names in a TSV file like the one written by WriteClasses, see ReadVocabulary
+func ReadClasses(r io.Reader) ([]string, error) {
+	return readNames(r, "class")
+}
+
+func readNames(r io.Reader, what string) ([]string, error) {
+	var names []string
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) == 1 {
+			names = append(names, fields[0])
+			continue
+		}
+
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			if line == 1 {
+				// the header
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid %s id %s", line, what, fields[0])
+		}
+		if id < 1 {
+			return nil, fmt.Errorf("line %d: %s id %d is less than 1", line, what, id)
+		}
+
+		for len(names) < id {
+			names = append(names, "")
+		}
+		if names[id-1] != "" {
+			return nil, fmt.Errorf("line %d: %s id %d is repeated", line, what, id)
+		}
+		names[id-1] = fields[1]
+	}
+
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("unable to read %ss: %s", what, scanner.Err())
+	}
+	return names, nil
+}
+
+// Returns the name with the 1-based id
+func lookup(names []string, id int, what string) (string, error) {
+	if id < 1 || id > len(names) || names[id-1] == "" {
+		return "", fmt.Errorf("unknown %s %d", what, id)
+	}
+	return names[id-1], nil
+}
+
+// Matrix values have to be term counts
+func parseCount(value string) (int32, error) {
+	x, err := strconv.ParseFloat(value, 64)
+	if err != nil || x < 0 || x != math.Trunc(x) || x > math.MaxInt32 {
+		return 0, fmt.Errorf("value %s isn't a term count", value)
+	}
+	return int32(x), nil
+}
+
+func addCount(doc *indices.InfoAndTerms, term string, count int32) {
+	if count == 0 {
+		return
+	}
+	doc.TermsAndCounts.PutLambda([]byte(term), func(x int32) int32 { return x + count }, count)
+	doc.Length += count
+}
+
+// Documents without terms are skipped, like in TotalIndex.AddMany
+func addDocument(ti *indices.TotalIndex, doc *indices.InfoAndTerms) {
+	if doc.TermsAndCounts.Empty() {
+		log.Printf("Document %s is empty", doc.Name)
+		return
+	}
+	ti.Add(doc)
+}
+
+// ReadSVMlight builds an index from a file with a line for every document: optional comma separated
+// class labels, term id:count pairs and optionally the name of the document in a comment after #.
+// The term ids are looked up in the vocabulary. If classes is nil the labels are the class names,
+// otherwise they are the ids of the classes in it. The label 0 is for documents without classes,
+// as WriteSVMlight writes them. Documents without names are named by their line.
+// The index has no positions, so phrase and proximity queries don't match anything
+func ReadSVMlight(r io.Reader, vocabulary []string, classes []string) (*indices.TotalIndex, error) {
+	ti := indices.NewTotalIndex()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<26)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+
+		doc := indices.NewInfoAndTerms()
+		doc.Name = strconv.Itoa(line)
+		if comment := strings.IndexByte(text, '#'); comment != -1 {
+			if name := strings.TrimSpace(text[comment+1:]); name != "" {
+				doc.Name = name
+			}
+			text = text[:comment]
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "0" {
+			fields = fields[1:]
+		} else if !strings.Contains(fields[0], ":") {
+			for _, label := range strings.Split(fields[0], ",") {
+				class := label
+				if classes != nil {
+					id, err := strconv.Atoi(label)
+					if err == nil {
+						class, err = lookup(classes, id, "class")
+					}
+					if err != nil {
+						return nil, fmt.Errorf("line %d: invalid class %s: %s", line, label, err)
+					}
+				}
+				doc.Classes = append(doc.Classes, class)
+			}
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			separator := strings.IndexByte(field, ':')
+			if separator == -1 {
+				return nil, fmt.Errorf("line %d: invalid feature %s", line, field)
+			}
+			if field[:separator] == "qid" {
+				continue
+			}
+
+			id, err := strconv.Atoi(field[:separator])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid term id %s", line, field[:separator])
+			}
+			term, err := lookup(vocabulary, id, "term")
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			count, err := parseCount(field[separator+1:])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+
+			addCount(doc, term, count)
+		}
+
+		addDocument(ti, doc)
+	}
+
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("unable to read documents: %s", scanner.Err())
+	}
+
+	ti.Finalise()
+	return ti, nil
+}
+
+// ReadMatrixMarket builds an index from a coordinate matrix with a row for every document and
+// a column for every term in the vocabulary, the values are the term counts.
+// The names and classes of the documents are read from a TSV file like the one written by
+// WriteDocuments, the classes are the ids in classes. Without it the documents are named
+// by their row and have no classes. The index has no positions, see ReadSVMlight
+func ReadMatrixMarket(r io.Reader, vocabulary []string, documents io.Reader, classes []string) (*indices.TotalIndex, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("no Matrix Market header")
+	}
+
+	header := strings.Fields(strings.ToLower(scanner.Text()))
+	if len(header) != 5 || header[0] != "%%matrixmarket" || header[1] != "matrix" || header[2] != "coordinate" {
+		return nil, fmt.Errorf("not a Matrix Market coordinate matrix")
+	}
+	if header[4] != "general" {
+		return nil, fmt.Errorf("unsupported symmetry %s", header[4])
+	}
+	pattern := header[3] == "pattern"
+	if !pattern && header[3] != "integer" && header[3] != "real" {
+		return nil, fmt.Errorf("unsupported field %s", header[3])
+	}
+
+	rows, columns, entries := -1, 0, 0
+	type entry struct {
+		term  string
+		count int32
+	}
+	var matrix [][]entry
+
+	for line := 2; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || text[0] == '%' {
+			continue
+		}
+		fields := strings.Fields(text)
+
+		if rows == -1 {
+			var err error
+			if len(fields) == 3 {
+				rows, err = strconv.Atoi(fields[0])
+				if err == nil {
+					columns, err = strconv.Atoi(fields[1])
+				}
+				if err == nil {
+					entries, err = strconv.Atoi(fields[2])
+				}
+			}
+			if len(fields) != 3 || err != nil || rows < 0 || columns > len(vocabulary) {
+				return nil, fmt.Errorf("line %d: invalid size %s for %d terms", line, text, len(vocabulary))
+			}
+			matrix = make([][]entry, rows)
+			continue
+		}
+
+		if len(fields) != 3 && !(pattern && len(fields) == 2) {
+			return nil, fmt.Errorf("line %d: invalid entry %s", line, text)
+		}
+
+		row, err := strconv.Atoi(fields[0])
+		if err != nil || row < 1 || row > rows {
+			return nil, fmt.Errorf("line %d: invalid row %s", line, fields[0])
+		}
+		column, err := strconv.Atoi(fields[1])
+		if err != nil || column < 1 || column > columns {
+			return nil, fmt.Errorf("line %d: invalid column %s", line, fields[1])
+		}
+		term, err := lookup(vocabulary, column, "term")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		count := int32(1)
+		if !pattern {
+			count, err = parseCount(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+		}
+
+		matrix[row-1] = append(matrix[row-1], entry{term, count})
+		entries--
+	}
+
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("unable to read matrix: %s", scanner.Err())
+	}
+	if rows == -1 {
+		return nil, fmt.Errorf("no matrix size")
+	}
+	if entries != 0 {
+		return nil, fmt.Errorf("the number of entries doesn't match the size")
+	}
+
+	docs := make([]*indices.InfoAndTerms, rows)
+	for row := range docs {
+		docs[row] = indices.NewInfoAndTerms()
+		docs[row].Name = strconv.Itoa(row + 1)
+	}
+	if documents != nil {
+		err := readDocuments(documents, docs, classes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ti := indices.NewTotalIndex()
+	for row, doc := range docs {
+		for _, e := range matrix[row] {
+			addCount(doc, e.term, e.count)
+		}
+		addDocument(ti, doc)
+	}
+
+	ti.Finalise()
+	return ti, nil
+}
+
+// Reads the names and classes of the rows from a TSV file written by WriteDocuments
+func readDocuments(r io.Reader, docs []*indices.InfoAndTerms, classes []string) error {
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 2 {
+			return fmt.Errorf("documents line %d: expected row, name and classes", line)
+		}
+
+		row, err := strconv.Atoi(fields[0])
+		if err != nil && line == 1 {
+			// the header
+			continue
+		}
+		if err != nil || row < 1 || row > len(docs) {
+			return fmt.Errorf("documents line %d: invalid row %s", line, fields[0])
+		}
+
+		doc := docs[row-1]
+		doc.Name = fields[1]
+		if len(fields) < 3 || fields[2] == "" {
+			continue
+		}
+
+		for _, label := range strings.Split(fields[2], ",") {
+			id, err := strconv.Atoi(label)
+			class := ""
+			if err == nil {
+				class, err = lookup(classes, id, "class")
+			}
+			if err != nil {
+				return fmt.Errorf("documents line %d: invalid class %s", line, label)
+			}
+			doc.Classes = append(doc.Classes, class)
+		}
+	}
+
+	if scanner.Err() != nil {
+		return fmt.Errorf("unable to read documents: %s", scanner.Err())
+	}
+	return nil
+}
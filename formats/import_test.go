@@ -0,0 +1,123 @@
+package formats
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/stretchr/testify/assert"
+)
+
+// The documents with their class names and term counts, in order
+func describe(ti *indices.TotalIndex) []string {
+	var docs []string
+	for docID, doc := range ti.Documents {
+		if doc.Deleted {
+			continue
+		}
+
+		var description []string
+		description = append(description, doc.Name)
+		for _, class := range doc.Classes {
+			description = append(description, string(ti.ClassNames.GetInverse(class)))
+		}
+		ti.LoopOverDocumentPostings(int32(docID), func(posting *indices.Posting) {
+			description = append(description, string(ti.Dictionary.GetInverse(posting.Index))+":"+formatFloat(float64(posting.Count)))
+		})
+		docs = append(docs, strings.Join(description, " "))
+	}
+	return docs
+}
+
+func exportTables(ti *indices.TotalIndex) ([]string, []string) {
+	var output bytes.Buffer
+	WriteVocabulary(&output, ti)
+	vocabulary, _ := ReadVocabulary(&output)
+
+	output.Reset()
+	WriteClasses(&output, ti)
+	classes, _ := ReadClasses(&output)
+
+	return vocabulary, classes
+}
+
+func TestReadNames(t *testing.T) {
+	assert := assert.New(t)
+
+	vocabulary, classes := exportTables(makeIndex())
+	assert.Equal([]string{"foo", "bar", "baz", "qux"}, vocabulary)
+	assert.Equal([]string{"sports", "politics"}, classes)
+
+	names, err := ReadVocabulary(strings.NewReader("foo\nbar\n"))
+	assert.Nil(err)
+	assert.Equal([]string{"foo", "bar"}, names)
+
+	_, err = ReadVocabulary(strings.NewReader("1\tfoo\n1\tbar\n"))
+	assert.NotNil(err)
+}
+
+func TestReadSVMlight(t *testing.T) {
+	assert := assert.New(t)
+
+	exported := makeIndex()
+	vocabulary, classes := exportTables(exported)
+
+	var output bytes.Buffer
+	assert.Nil(WriteSVMlight(&output, exported, TF))
+	ti, err := ReadSVMlight(&output, vocabulary, classes)
+	assert.Nil(err)
+	assert.Empty(ti.Verify())
+	assert.Equal(describe(exported), describe(ti))
+	assert.Equal(int32(3), ti.Documents[0].Length)
+	assert.Equal(int32(2), ti.Documents[0].UniqueLength)
+
+	// labels are class names without a classes file and empty lines are skipped
+	ti, err = ReadSVMlight(strings.NewReader("spam 2:1 qid:3 1:0\n\nham,spam 1:2.0\n"), vocabulary, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"1 spam bar:1", "3 ham spam foo:2"}, describe(ti))
+
+	// 0 is no class, with and without a classes file, and the label can be left out
+	for _, classes := range [][]string{classes, nil} {
+		ti, err = ReadSVMlight(strings.NewReader("0 1:1 # first\n2:1 # second\n"), vocabulary, classes)
+		assert.Nil(err)
+		assert.Equal([]string{"first foo:1", "second bar:1"}, describe(ti))
+	}
+
+	_, err = ReadSVMlight(strings.NewReader("1 5:1\n"), vocabulary, classes)
+	assert.NotNil(err)
+	_, err = ReadSVMlight(strings.NewReader("1 1:0.5\n"), vocabulary, classes)
+	assert.NotNil(err)
+	_, err = ReadSVMlight(strings.NewReader("3 1:1\n"), vocabulary, classes)
+	assert.NotNil(err)
+}
+
+func TestReadMatrixMarket(t *testing.T) {
+	assert := assert.New(t)
+
+	exported := makeIndex()
+	vocabulary, classes := exportTables(exported)
+
+	var matrix, documents bytes.Buffer
+	assert.Nil(WriteMatrixMarket(&matrix, exported, TF))
+	assert.Nil(WriteDocuments(&documents, exported))
+	ti, err := ReadMatrixMarket(&matrix, vocabulary, &documents, classes)
+	assert.Nil(err)
+	assert.Empty(ti.Verify())
+	assert.Equal(describe(exported), describe(ti))
+
+	// without documents the rows are the names and the empty second row is skipped
+	ti, err = ReadMatrixMarket(strings.NewReader("%%MatrixMarket matrix coordinate pattern general\n% comment\n3 4 2\n3 4\n1 2\n"), vocabulary, nil, nil)
+	assert.Nil(err)
+	assert.Equal([]string{"1 bar:1", "3 qux:1"}, describe(ti))
+
+	_, err = ReadMatrixMarket(strings.NewReader("%%MatrixMarket matrix array real general\n1 1\n1\n"), vocabulary, nil, nil)
+	assert.NotNil(err)
+	_, err = ReadMatrixMarket(strings.NewReader("%%MatrixMarket matrix coordinate integer general\n1 4 2\n1 1 1\n"), vocabulary, nil, nil)
+	assert.NotNil(err)
+	_, err = ReadMatrixMarket(strings.NewReader("%%MatrixMarket matrix coordinate integer general\n1 5 1\n1 5 1\n"), vocabulary, nil, nil)
+	assert.NotNil(err)
+	// a gap in the vocabulary
+	_, err = ReadMatrixMarket(strings.NewReader("%%MatrixMarket matrix coordinate integer general\n1 2 1\n1 2 1\n"), []string{"foo", ""}, nil, nil)
+	assert.Equal("line 3: unknown term 2", err.Error())
+}
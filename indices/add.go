@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"sync"
 
 	"github.com/bitterfly/search/trie"
 )
@@ -14,17 +15,35 @@ type InfoAndTerms struct {
 	Classes        []string
 	Length         int32
 	TermsAndCounts trie.Trie
+	Positions      map[string][]int32
+
+	// Not indexed, only passed along for a document store
+	Date string
+	Body string
 }
 
 func NewInfoAndTerms() *InfoAndTerms {
 	return &InfoAndTerms{
 		TermsAndCounts: *trie.New(),
+		Positions:      make(map[string][]int32),
 	}
 }
 
+// Counts the term as the next one in the document
+func (d *InfoAndTerms) AddTerm(term string) {
+	d.TermsAndCounts.PutLambda(
+		[]byte(term),
+		func(x int32) int32 { return x + 1 },
+		1,
+	)
+	d.Positions[term] = append(d.Positions[term], d.Length)
+	d.Length += 1
+}
+
 type TermAndCount struct {
-	TermID int32
-	Count  int32
+	TermID    int32
+	Count     int32
+	Positions []int32
 }
 
 func (d *InfoAndTerms) String() string {
@@ -47,25 +66,102 @@ func (d *InfoAndTerms) String() string {
 }
 
 func (t *TotalIndex) AddMany(infosAndTerms <-chan *InfoAndTerms) {
+	t.AddManyWith(infosAndTerms, nil)
+}
+
+// Like AddMany, but calls added (if not nil) with the id given to every added document
+func (t *TotalIndex) AddManyWith(infosAndTerms <-chan *InfoAndTerms, added func(int32, *InfoAndTerms)) {
 	for it := range infosAndTerms {
 
 		if it.TermsAndCounts.Empty() {
 			log.Printf("Document %s is empty", it.Name)
 		} else {
-			t.Add(it)
+			docID := t.Add(it)
+			if added != nil {
+				added(docID, it)
+			}
 		}
 	}
 }
 
-func (t *TotalIndex) Add(d *InfoAndTerms) {
+// AddManyParallel is like AddMany, but the documents are indexed by the workers into shards
+// with their own dictionaries, which are merged into the index in the end.
+// The documents get their ids in the order of the shards, not the order they came in.
+// The index can't have deleted documents, see Merge. If the shards can't be merged,
+// the index is left as it was and the documents which were read are lost
+func (t *TotalIndex) AddManyParallel(infosAndTerms <-chan *InfoAndTerms, workers int) error {
+	return t.AddManyParallelWith(infosAndTerms, workers, nil)
+}
+
+// Like AddManyParallel, but calls added (if not nil) with the id given to every added document
+// after the shards are merged. The documents it gets don't have their terms any more, but until
+// the merge all of them are kept in memory with their names, classes, dates and bodies,
+// so with a callback the whole text of the collection is held at once. Use AddManyWith when
+// that doesn't fit in memory
+func (t *TotalIndex) AddManyParallelWith(infosAndTerms <-chan *InfoAndTerms, workers int, added func(int32, *InfoAndTerms)) error {
+	shards := make([]*TotalIndex, workers)
+	shardDocuments := make([][]*InfoAndTerms, workers)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(workers)
+	for w := range shards {
+		shards[w] = NewTotalIndex()
+		go func(w int) {
+			shards[w].AddManyWith(infosAndTerms, func(_ int32, it *InfoAndTerms) {
+				if added != nil {
+					shardDocuments[w] = append(shardDocuments[w], &InfoAndTerms{
+						Name:    it.Name,
+						Classes: it.Classes,
+						Length:  it.Length,
+						Date:    it.Date,
+						Body:    it.Body,
+					})
+				}
+			})
+			wg.Done()
+		}(w)
+	}
+	wg.Wait()
+
+	docID := int32(len(t.Documents))
+	merged, err := mergeAll(append([]*TotalIndex{t}, shards...))
+	if err != nil {
+		return fmt.Errorf("unable to merge shards: %s", err)
+	}
+	merged.Metadata = t.Metadata
+	*t = *merged
+
+	for w := range shardDocuments {
+		for _, it := range shardDocuments[w] {
+			added(docID, it)
+			docID++
+		}
+	}
+	return nil
+}
+
+// Add indexes the document and returns its id.
+// A compressed index is decompressed first and the stored score bounds are dropped,
+// since they may not hold any more
+func (t *TotalIndex) Add(d *InfoAndTerms) int32 {
+	t.MaxScores = nil
+
+	for _, index := range []*Index{&t.Forward, &t.Inverse} {
+		err := index.Decompress()
+		if err != nil {
+			panic(fmt.Sprintf("unable to add to a compressed index: %s", err))
+		}
+	}
+
 	var sortedTermsAndCounts []TermAndCount
 
 	d.TermsAndCounts.Walk(func(term []byte, count int32) {
 		sortedTermsAndCounts = append(
 			sortedTermsAndCounts,
 			TermAndCount{
-				TermID: t.Dictionary.Get(term),
-				Count:  count,
+				TermID:    t.Dictionary.Get(term),
+				Count:     count,
+				Positions: d.Positions[string(term)],
 			},
 		)
 	})
@@ -134,18 +230,51 @@ func (t *TotalIndex) Add(d *InfoAndTerms) {
 				panic(fmt.Sprintf("lenPostingList: %d, lenPostings: %d, termId: %d\n", len(t.Inverse.PostingLists), len(t.Inverse.Postings), term.TermID))
 			}
 
-			t.Inverse.Postings = append(t.Inverse.Postings, Posting{Index: documentIndex, Count: term.Count, NextPostingIndex: -1})
+			if t.Inverse.PostingLists[term.TermID].LastIndex != int32(len(t.Inverse.Postings))-1 {
+				// the list continues somewhere else in Postings
+				t.Inverse.Compact = false
+			}
+
+			t.Inverse.Postings = append(t.Inverse.Postings, Posting{Index: documentIndex, Count: term.Count, Positions: term.Positions, NextPostingIndex: -1})
 			t.Inverse.Postings[t.Inverse.PostingLists[term.TermID].LastIndex].NextPostingIndex = int32(len(t.Inverse.Postings)) - 1
 			t.Inverse.PostingLists[term.TermID].LastIndex = int32(len(t.Inverse.Postings)) - 1
 			t.Inverse.PostingLists[term.TermID].Len += 1
 		} else if int32(len(t.Inverse.PostingLists)) == term.TermID {
 			//PL -> [f:0 l:0]
 			// [index: 0, count: 2, NextPI: -1]
-			t.Inverse.PostingLists = append(t.Inverse.PostingLists, PostingList{FirstIndex: int32(len(t.Inverse.Postings)), LastIndex: int32(len(t.Inverse.Postings)), Len: 0})
-			t.Inverse.Postings = append(t.Inverse.Postings, Posting{Index: documentIndex, Count: term.Count, NextPostingIndex: -1})
+			// the new list already has this posting. It used to start with Len 0, so the document frequencies
+			// of legacy indices are one too small until they're deserialised, see recountLengths
+			t.Inverse.PostingLists = append(t.Inverse.PostingLists, PostingList{FirstIndex: int32(len(t.Inverse.Postings)), LastIndex: int32(len(t.Inverse.Postings)), Len: 1})
+			t.Inverse.Postings = append(t.Inverse.Postings, Posting{Index: documentIndex, Count: term.Count, Positions: term.Positions, NextPostingIndex: -1})
 		} else {
 			panic(fmt.Sprintf("lenPostingList: %d, lenPostings: %d, termId: %d\n", len(t.Inverse.PostingLists), len(t.Inverse.Postings), term.TermID))
 		}
 	}
 
+	return documentIndex
+}
+
+// Extract returns the document as it would be given to Add, so it can be added to another index
+func (t *TotalIndex) Extract(docID int32) *InfoAndTerms {
+	info := &t.Documents[docID]
+
+	d := NewInfoAndTerms()
+	d.Name = info.Name
+	d.Length = info.Length
+	for _, class := range info.Classes {
+		d.Classes = append(d.Classes, string(t.ClassNames.GetInverse(class)))
+	}
+
+	t.Forward.loop(docID, func(posting *Posting) {
+		term := t.Dictionary.GetInverse(posting.Index)
+		d.TermsAndCounts.Put(term, posting.Count)
+
+		// only the inverse index has the positions
+		cursor := t.Inverse.Cursor(posting.Index)
+		if cursor.Advance(docID) && cursor.Posting().Index == docID && cursor.Posting().Positions != nil {
+			d.Positions[string(term)] = append([]int32(nil), cursor.Posting().Positions...)
+		}
+	})
+
+	return d
 }
@@ -1,6 +1,11 @@
 package indices
 
 import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -198,6 +203,11 @@ func TestAdd(t *testing.T) {
 		ti.Inverse.Postings[ti.Inverse.PostingLists[ti.Dictionary.Get([]byte("bar"))].LastIndex].NextPostingIndex,
 	)
 
+	// Number of documents each term occurs in
+	assert.Equal(1, ti.Inverse.PostingLists[ti.Dictionary.Get([]byte("foo"))].Len)
+	assert.Equal(2, ti.Inverse.PostingLists[ti.Dictionary.Get([]byte("bar"))].Len)
+	assert.Equal(1, ti.Inverse.PostingLists[ti.Dictionary.Get([]byte("qux"))].Len)
+
 	assert.Equal(
 		int32(2),
 		ti.Documents[0].UniqueLength,
@@ -208,3 +218,199 @@ func TestAdd(t *testing.T) {
 		ti.Documents[1].UniqueLength,
 	)
 }
+
+func TestAdd_Positions(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := NewInfoAndTerms()
+	for _, term := range []string{"foo", "bar", "foo", "qux"} {
+		doc.AddTerm(term)
+	}
+
+	assert.Equal(int32(4), doc.Length)
+
+	ti := NewTotalIndex()
+	ti.Add(doc)
+
+	foo := ti.Inverse.Postings[ti.Inverse.PostingLists[ti.Dictionary.Get([]byte("foo"))].FirstIndex]
+	assert.Equal(int32(2), foo.Count)
+	assert.Equal([]int32{0, 2}, foo.Positions)
+
+	qux := ti.Inverse.Postings[ti.Inverse.PostingLists[ti.Dictionary.Get([]byte("qux"))].FirstIndex]
+	assert.Equal([]int32{3}, qux.Positions)
+
+	// forward postings don't keep positions
+	assert.Nil(ti.Forward.Postings[ti.Forward.PostingLists[0].FirstIndex].Positions)
+}
+
+func TestAdd_Append(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar", "qux"))
+	ti.Finalise()
+	ti.Normalise()
+	ti.MaxScores = map[string][]float64{"test": {1, 1, 1}}
+
+	var buffer bytes.Buffer
+	assert.Nil(ti.SerialiseTo(&buffer))
+
+	loaded := NewTotalIndex()
+	assert.Nil(loaded.DeserialiseFrom(&buffer))
+	loaded.Inverse.Compress()
+
+	appended := int32(len(loaded.Documents))
+	assert.Equal(int32(2), loaded.Add(makeDocument("qux", "new", "new")))
+	loaded.Finalise()
+	assert.Empty(loaded.Verify())
+	loaded.NormaliseFrom(appended)
+
+	// old terms keep their ids
+	for _, term := range []string{"foo", "bar", "qux"} {
+		assert.Equal(ti.Dictionary.Find([]byte(term)), loaded.Dictionary.Find([]byte(term)))
+	}
+	assert.Equal(int32(3), loaded.Dictionary.Find([]byte("new")))
+
+	assert.Equal([]int32{1, 2}, termDocuments(loaded, "qux"))
+	assert.Equal([]int32{2}, termDocuments(loaded, "new"))
+	assert.Nil(loaded.MaxScores)
+
+	postings := loaded.DocumentPostings(2)
+	assert.Equal(float32(0.5), postings[0].NormalisedCount)
+	assert.Equal(float32(1), postings[1].NormalisedCount)
+}
+
+func TestExtract(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := makeDocument("qux", "foo", "qux")
+	doc.Name = "doc"
+	doc.Classes = []string{"sports"}
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(doc)
+	ti.Finalise()
+
+	other := NewTotalIndex()
+	other.Add(makeDocument("baz"))
+	docID := other.Add(ti.Extract(1))
+	other.Finalise()
+	assert.Empty(other.Verify())
+
+	assert.Equal(int32(1), docID)
+	assert.Equal("doc", other.Documents[1].Name)
+	assert.Equal(int32(3), other.Documents[1].Length)
+	assert.Equal(int32(2), other.Documents[1].UniqueLength)
+	assert.Equal([]byte("sports"), other.ClassNames.GetInverse(other.Documents[1].Classes[0]))
+
+	qux := other.TermPostings(other.Dictionary.Find([]byte("qux")))
+	assert.Len(qux, 1)
+	assert.Equal(int32(2), qux[0].Count)
+	assert.Equal([]int32{0, 2}, qux[0].Positions)
+	assert.Equal([]int32{1}, termDocuments(other, "foo"))
+}
+
+// Documents with Zipf distributed words, so there are both very common and rare terms
+func makeRandomDocuments(count int, length int) []*InfoAndTerms {
+	random := rand.New(rand.NewSource(7))
+	zipf := rand.NewZipf(random, 1.1, 2, 20000)
+
+	docs := make([]*InfoAndTerms, count)
+	for i := range docs {
+		docs[i] = NewInfoAndTerms()
+		docs[i].Name = fmt.Sprintf("doc%d", i)
+		for j := 1 + random.Intn(length); j > 0; j-- {
+			docs[i].AddTerm(fmt.Sprintf("w%d", zipf.Uint64()))
+		}
+	}
+	return docs
+}
+
+func feed(docs []*InfoAndTerms) <-chan *InfoAndTerms {
+	infosAndTerms := make(chan *InfoAndTerms, 100)
+	go func() {
+		for _, doc := range docs {
+			infosAndTerms <- doc
+		}
+		close(infosAndTerms)
+	}()
+	return infosAndTerms
+}
+
+// The terms of the document with their counts and positions
+func documentTerms(ti *TotalIndex, docID int32) []string {
+	var terms []string
+	ti.LoopOverDocumentPostings(docID, func(posting *Posting) {
+		termID := posting.Index
+		cursor := ti.TermCursor(termID)
+		cursor.Advance(docID)
+		terms = append(terms, fmt.Sprintf("%s %d %v", ti.Dictionary.GetInverse(termID), posting.Count, cursor.Posting().Positions))
+	})
+	sort.Strings(terms)
+	return terms
+}
+
+func TestAddManyParallel(t *testing.T) {
+	assert := assert.New(t)
+
+	docs := makeRandomDocuments(500, 30)
+
+	sequential := NewTotalIndex()
+	sequential.AddMany(feed(docs))
+
+	parallel := NewTotalIndex()
+	parallel.Add(makeDocument("first"))
+	parallel.Metadata.Source = "test"
+	parallel.MaxScores = map[string][]float64{"bm25": {1}}
+
+	names := make(map[int32]string)
+	assert.Nil(parallel.AddManyParallelWith(feed(docs), 4, func(docID int32, it *InfoAndTerms) {
+		names[docID] = it.Name
+	}))
+	assert.Empty(parallel.Verify())
+
+	assert.Len(parallel.Documents, 501)
+	assert.Len(names, 500)
+	assert.Equal(sequential.Dictionary.Size+1, parallel.Dictionary.Size)
+	assert.Equal(int32(0), parallel.Dictionary.Find([]byte("first")))
+	assert.Equal("test", parallel.Metadata.Source)
+	assert.Nil(parallel.MaxScores)
+
+	for docID := range sequential.Documents {
+		var expected int32 = -1
+		for parallelID, name := range names {
+			if name == sequential.Documents[docID].Name {
+				expected = parallelID
+			}
+		}
+
+		assert.Equal(sequential.Documents[docID].Name, parallel.Documents[expected].Name)
+		assert.Equal(documentTerms(sequential, int32(docID)), documentTerms(parallel, expected))
+	}
+
+	// the shards can't be merged into an index with deleted documents
+	assert.Nil(parallel.Delete(0))
+	assert.NotNil(parallel.AddManyParallel(feed(docs), 4))
+	assert.Len(parallel.Documents, 501)
+	assert.Empty(parallel.Verify())
+}
+
+func benchmarkAddMany(b *testing.B, workers int) {
+	docs := makeRandomDocuments(10000, 100)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ti := NewTotalIndex()
+		if workers == 1 {
+			ti.AddMany(feed(docs))
+			ti.Finalise()
+		} else if err := ti.AddManyParallel(feed(docs), workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAddMany(b *testing.B)         { benchmarkAddMany(b, 1) }
+func BenchmarkAddManyParallel(b *testing.B) { benchmarkAddMany(b, runtime.NumCPU()) }
@@ -0,0 +1,344 @@
+package indices
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Postings in an encoded list are grouped in blocks of BlockSize
+const BlockSize = 128
+
+// EncodePostings compresses a sorted posting list.
+//
+// The list starts with the number of postings followed by blocks of BlockSize postings.
+// Every block starts with the delta between its last index and the last index of the
+// previous block and the length of the block in bytes, so whole blocks can be skipped.
+// Every posting in a block is the delta from the previous index, the count, the number
+// of positions and the positions as deltas. All numbers are unsigned varints.
+// NormalisedCount isn't kept, it can be recomputed with Normalise.
+func EncodePostings(postings []Posting) []byte {
+	e := NewPostingEncoder()
+	for i := range postings {
+		e.Add(&postings[i])
+	}
+	return e.Bytes()
+}
+
+// PostingEncoder encodes a list like EncodePostings a posting at a time,
+// so that the postings don't all have to be in memory
+type PostingEncoder struct {
+	scratch [binary.MaxVarintLen64]byte
+	body    []byte
+	length  int
+
+	block         []byte
+	blockLength   int
+	blockPrevious int32
+	previous      int32
+}
+
+func NewPostingEncoder() *PostingEncoder {
+	return &PostingEncoder{
+		blockPrevious: -1,
+		previous:      -1,
+	}
+}
+
+func (e *PostingEncoder) putUvarint(buffer []byte, x uint64) []byte {
+	return append(buffer, e.scratch[:binary.PutUvarint(e.scratch[:], x)]...)
+}
+
+// Add encodes the posting, its index has to be greater than the index of the previous one
+func (e *PostingEncoder) Add(posting *Posting) {
+	e.block = e.putUvarint(e.block, uint64(posting.Index-e.previous))
+	e.block = e.putUvarint(e.block, uint64(posting.Count))
+	e.block = e.putUvarint(e.block, uint64(len(posting.Positions)))
+
+	previousPosition := int32(0)
+	for _, position := range posting.Positions {
+		e.block = e.putUvarint(e.block, uint64(position-previousPosition))
+		previousPosition = position
+	}
+
+	e.previous = posting.Index
+	e.length++
+	e.blockLength++
+	if e.blockLength == BlockSize {
+		e.flushBlock()
+	}
+}
+
+func (e *PostingEncoder) flushBlock() {
+	if e.blockLength == 0 {
+		return
+	}
+
+	e.body = e.putUvarint(e.body, uint64(e.previous-e.blockPrevious))
+	e.body = e.putUvarint(e.body, uint64(len(e.block)))
+	e.body = append(e.body, e.block...)
+
+	e.block = e.block[:0]
+	e.blockLength = 0
+	e.blockPrevious = e.previous
+}
+
+// Len returns the number of postings added so far
+func (e *PostingEncoder) Len() int {
+	return e.length
+}
+
+// Bytes returns the encoded list, no more postings can be added after it
+func (e *PostingEncoder) Bytes() []byte {
+	e.flushBlock()
+
+	data := make([]byte, 0, binary.MaxVarintLen64+len(e.body))
+	data = e.putUvarint(data, uint64(e.length))
+	return append(data, e.body...)
+}
+
+// DecodePostings is the inverse of EncodePostings
+func DecodePostings(data []byte) ([]Posting, error) {
+	decoder := NewPostingDecoder(data)
+
+	postings := make([]Posting, 0, decoder.Len())
+	for decoder.Next() {
+		postings = append(postings, *decoder.Posting())
+	}
+
+	return postings, decoder.Err()
+}
+
+// PostingDecoder iterates over an encoded posting list
+type PostingDecoder struct {
+	data []byte
+	pos  int
+
+	length    int
+	remaining int
+
+	// postings left in the current block, where it ends and its last index
+	blockRemaining int
+	blockEnd       int
+	blockLast      int32
+	previous       int32
+
+	// whether posting holds a decoded posting
+	valid   bool
+	posting Posting
+	err     error
+}
+
+func NewPostingDecoder(data []byte) *PostingDecoder {
+	d := &PostingDecoder{
+		data:     data,
+		previous: -1,
+	}
+
+	length, ok := d.uvarint()
+	if !ok {
+		d.err = fmt.Errorf("missing length of posting list")
+	}
+	// every posting takes at least three bytes, so a corrupt length can't make Len huge
+	if length > uint64(len(data)) {
+		d.err = fmt.Errorf("length %d of posting list is more than its %d bytes can hold", length, len(data))
+		length = 0
+	}
+	d.length = int(length)
+	d.remaining = d.length
+	d.blockLast = -1
+
+	return d
+}
+
+// Returns the number of postings in the list
+func (d *PostingDecoder) Len() int {
+	return d.length
+}
+
+func (d *PostingDecoder) Err() error {
+	return d.err
+}
+
+func (d *PostingDecoder) uvarint() (uint64, bool) {
+	x, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, false
+	}
+	d.pos += n
+	return x, true
+}
+
+// Reads the header of the next block
+func (d *PostingDecoder) nextBlock() bool {
+	lastDelta, ok := d.uvarint()
+	if !ok {
+		d.err = fmt.Errorf("corrupt block header at byte %d", d.pos)
+		return false
+	}
+	length, ok := d.uvarint()
+	if !ok || length > uint64(len(d.data)-d.pos) {
+		d.err = fmt.Errorf("corrupt block header at byte %d", d.pos)
+		return false
+	}
+
+	d.blockEnd = d.pos + int(length)
+	d.blockLast += int32(lastDelta)
+	d.blockRemaining = BlockSize
+	if d.remaining < BlockSize {
+		d.blockRemaining = d.remaining
+	}
+	return true
+}
+
+// Next decodes the next posting, it returns false at the end of the list or on error
+func (d *PostingDecoder) Next() bool {
+	d.valid = false
+	if d.err != nil || d.remaining == 0 {
+		return false
+	}
+
+	if d.blockRemaining == 0 && !d.nextBlock() {
+		return false
+	}
+
+	delta, ok := d.uvarint()
+	count, okCount := d.uvarint()
+	numPositions, okPositions := d.uvarint()
+	if !ok || !okCount || !okPositions || numPositions > uint64(len(d.data)-d.pos) {
+		d.err = fmt.Errorf("corrupt posting at byte %d", d.pos)
+		return false
+	}
+
+	d.previous += int32(delta)
+	d.posting = Posting{
+		Index:            d.previous,
+		Count:            int32(count),
+		NextPostingIndex: -1,
+	}
+
+	if numPositions > 0 {
+		d.posting.Positions = make([]int32, numPositions)
+		position := int32(0)
+		for i := range d.posting.Positions {
+			positionDelta, ok := d.uvarint()
+			if !ok {
+				d.err = fmt.Errorf("corrupt positions at byte %d", d.pos)
+				return false
+			}
+			position += int32(positionDelta)
+			d.posting.Positions[i] = position
+		}
+	}
+
+	d.remaining--
+	d.blockRemaining--
+	d.valid = true
+	return true
+}
+
+// Advance moves to the first posting with index at least target, unless the current one already is.
+// Blocks which end before target are skipped without decoding them.
+// It returns false if there's no such posting.
+func (d *PostingDecoder) Advance(target int32) bool {
+	if d.valid && d.posting.Index >= target {
+		return true
+	}
+
+	for d.err == nil && d.remaining > 0 {
+		if d.blockRemaining == 0 && !d.nextBlock() {
+			return false
+		}
+		if d.blockLast >= target {
+			break
+		}
+
+		d.pos = d.blockEnd
+		d.remaining -= d.blockRemaining
+		d.blockRemaining = 0
+		d.previous = d.blockLast
+	}
+
+	for d.Next() {
+		if d.posting.Index >= target {
+			return true
+		}
+	}
+	return false
+}
+
+// Posting returns the last decoded posting, it's overwritten by the next call to Next
+func (d *PostingDecoder) Posting() *Posting {
+	return &d.posting
+}
+
+// EncodeList returns the list encoded with EncodePostings
+func (i *Index) EncodeList(listID int32) []byte {
+	if i.Encoded != nil {
+		return i.Encoded[listID]
+	}
+
+	e := NewPostingEncoder()
+	i.loop(listID, e.Add)
+	return e.Bytes()
+}
+
+// Compress replaces the postings with their encoded lists, which are used from then on.
+// The posting lists keep their lengths, but aren't compact any more.
+// NormalisedCount is discarded, TotalIndex.Normalise decompresses the forward index and recomputes it.
+func (i *Index) Compress() {
+	if i.Encoded != nil {
+		return
+	}
+
+	encoded := make([][]byte, len(i.PostingLists))
+	for listID := range i.PostingLists {
+		encoded[listID] = i.EncodeList(int32(listID))
+	}
+
+	for listID := range i.PostingLists {
+		i.PostingLists[listID].FirstIndex = -1
+		i.PostingLists[listID].LastIndex = -1
+	}
+
+	i.Encoded = encoded
+	i.Postings = nil
+	i.Compact = false
+}
+
+// Decompress decodes the lists back into a compact index
+func (i *Index) Decompress() error {
+	if i.Encoded == nil {
+		return nil
+	}
+
+	var postings []Posting
+	for listID := range i.PostingLists {
+		decoded, err := DecodePostings(i.Encoded[listID])
+		if err != nil {
+			return fmt.Errorf("unable to decode list %d: %s", listID, err)
+		}
+
+		postingList := &i.PostingLists[listID]
+		postingList.Len = len(decoded)
+		postingList.FirstIndex = -1
+		postingList.LastIndex = -1
+
+		if len(decoded) > 0 {
+			postingList.FirstIndex = int32(len(postings))
+			postingList.LastIndex = int32(len(postings) + len(decoded) - 1)
+		}
+
+		for p := range decoded {
+			decoded[p].NextPostingIndex = int32(len(postings) + p + 1)
+		}
+		if len(decoded) > 0 {
+			decoded[len(decoded)-1].NextPostingIndex = -1
+		}
+		postings = append(postings, decoded...)
+	}
+
+	i.Postings = postings
+	i.Encoded = nil
+	i.Compact = true
+	return nil
+}
@@ -0,0 +1,180 @@
+package indices
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A posting list with geometrically distributed gaps and counts, like a term in a real collection
+func makePostings(length int, withPositions bool) []Posting {
+	random := rand.New(rand.NewSource(42))
+	postings := make([]Posting, length)
+
+	index := int32(-1)
+	for i := range postings {
+		index += 1 + int32(random.ExpFloat64()*20)
+		count := 1 + int32(random.ExpFloat64())
+
+		postings[i] = Posting{Index: index, Count: count, NextPostingIndex: -1}
+		if withPositions {
+			position := int32(0)
+			for j := int32(0); j < count; j++ {
+				position += 1 + int32(random.ExpFloat64()*50)
+				postings[i].Positions = append(postings[i].Positions, position)
+			}
+		}
+	}
+
+	return postings
+}
+
+func TestEncodePostings(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, length := range []int{0, 1, BlockSize - 1, BlockSize, BlockSize + 1, 5*BlockSize + 17} {
+		postings := makePostings(length, true)
+
+		decoded, err := DecodePostings(EncodePostings(postings))
+		assert.Nil(err)
+		assert.Equal(len(postings), len(decoded), "length %d", length)
+		for i := range postings {
+			assert.Equal(postings[i], decoded[i], "length %d, posting %d", length, i)
+		}
+	}
+}
+
+func TestPostingEncoder(t *testing.T) {
+	assert := assert.New(t)
+
+	// the parts of a list, encoded separately, are streamed into one list
+	postings := makePostings(5*BlockSize+17, true)
+	e := NewPostingEncoder()
+	for _, part := range [][]Posting{postings[:70], postings[70:70], postings[70:400], postings[400:]} {
+		decoder := NewPostingDecoder(EncodePostings(part))
+		for decoder.Next() {
+			e.Add(decoder.Posting())
+		}
+		assert.Nil(decoder.Err())
+	}
+
+	assert.Equal(len(postings), e.Len())
+	assert.Equal(EncodePostings(postings), e.Bytes())
+}
+
+func TestDecodePostings_Corrupt(t *testing.T) {
+	assert := assert.New(t)
+
+	data := EncodePostings(makePostings(300, true))
+	_, err := DecodePostings(data[:len(data)/2])
+	assert.NotNil(err)
+
+	_, err = DecodePostings(nil)
+	assert.NotNil(err)
+}
+
+func TestCompress(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar", "qux"))
+	ti.Add(makeDocument("foo", "qux", "qux"))
+	ti.Finalise()
+
+	uncompressed := ti.Inverse.Postings
+
+	ti.Inverse.Compress()
+	assert.Nil(ti.Inverse.Postings)
+	assert.Empty(ti.Verify())
+
+	assert.Equal([]int32{0, 2}, termDocuments(ti, "foo"))
+	assert.Equal([]int32{1, 2}, termDocuments(ti, "qux"))
+	assert.Equal(2, ti.Inverse.PostingLists[ti.Dictionary.Find([]byte("qux"))].Len)
+
+	// compressed lists survive serialisation
+	var buffer bytes.Buffer
+	assert.Nil(ti.SerialiseTo(&buffer))
+	loaded := NewTotalIndex()
+	assert.Nil(loaded.DeserialiseFrom(&buffer))
+	assert.Equal([]int32{0, 2}, termDocuments(loaded, "foo"))
+
+	assert.Nil(loaded.Inverse.Decompress())
+	assert.True(loaded.Inverse.Compact)
+	assert.Equal(uncompressed, loaded.Inverse.Postings)
+
+	// adding decompresses
+	ti.Add(makeDocument("foo"))
+	assert.Nil(ti.Inverse.Encoded)
+	assert.Equal([]int32{0, 2, 3}, termDocuments(ti, "foo"))
+}
+
+func benchmarkSize(b *testing.B, withPositions bool) {
+	postings := makePostings(10000, withPositions)
+
+	var raw bytes.Buffer
+	err := gob.NewEncoder(&raw).Encode(postings)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var encoded []byte
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		encoded = EncodePostings(postings)
+	}
+
+	b.ReportMetric(float64(raw.Len())/float64(len(postings)), "gob-bytes/posting")
+	b.ReportMetric(float64(len(encoded))/float64(len(postings)), "encoded-bytes/posting")
+}
+
+func BenchmarkEncode(b *testing.B)              { benchmarkSize(b, false) }
+func BenchmarkEncodeWithPositions(b *testing.B) { benchmarkSize(b, true) }
+
+func BenchmarkDecode(b *testing.B) {
+	data := EncodePostings(makePostings(10000, true))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		sum := int32(0)
+		decoder := NewPostingDecoder(data)
+		for decoder.Next() {
+			sum += decoder.Posting().Count
+		}
+	}
+}
+
+func BenchmarkIterateRaw(b *testing.B) {
+	postings := makePostings(10000, true)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		sum := int32(0)
+		for i := range postings {
+			sum += postings[i].Count
+		}
+	}
+}
+
+func BenchmarkDecodeGob(b *testing.B) {
+	var raw bytes.Buffer
+	err := gob.NewEncoder(&raw).Encode(makePostings(10000, true))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var postings []Posting
+		err := gob.NewDecoder(bytes.NewReader(raw.Bytes())).Decode(&postings)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
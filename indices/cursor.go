@@ -0,0 +1,126 @@
+package indices
+
+import "sort"
+
+// Cursor walks a posting list in increasing order of index.
+// It starts before the first posting, so Next or Advance has to be called first.
+type Cursor struct {
+	// used for encoded lists
+	decoder *PostingDecoder
+
+	postings []Posting
+	pos      int
+
+	// if set, postings for which it's true are skipped
+	skip func(index int32) bool
+}
+
+// Cursor returns a cursor over the list.
+// Encoded lists skip whole blocks on Advance, compact lists gallop over the slice.
+// Lists which aren't compact are copied first.
+func (i *Index) Cursor(listID int32) *Cursor {
+	if i.Encoded != nil {
+		return NewEncodedCursor(i.Encoded[listID])
+	}
+
+	if i.Compact {
+		return &Cursor{postings: i.List(listID), pos: -1}
+	}
+
+	postings := make([]Posting, 0, i.PostingLists[listID].Len)
+	i.loop(listID, func(posting *Posting) {
+		postings = append(postings, *posting)
+	})
+	return &Cursor{postings: postings, pos: -1}
+}
+
+// NewEncodedCursor returns a cursor over a list encoded with EncodePostings
+func NewEncodedCursor(data []byte) *Cursor {
+	return &Cursor{decoder: NewPostingDecoder(data)}
+}
+
+// TermCursor returns a cursor over the documents containing the term, deleted documents are skipped
+func (ti *TotalIndex) TermCursor(termID int32) *Cursor {
+	cursor := ti.Inverse.Cursor(termID)
+	if ti.NumDeleted != 0 {
+		cursor.skip = func(docID int32) bool {
+			return ti.Documents[docID].Deleted
+		}
+	}
+	return cursor
+}
+
+// Returns the number of postings in the list, including the skipped ones
+func (c *Cursor) Len() int {
+	if c.decoder != nil {
+		return c.decoder.Len()
+	}
+	return len(c.postings)
+}
+
+// Next moves to the next posting, it returns false at the end of the list
+func (c *Cursor) Next() bool {
+	for c.next() {
+		if c.skip == nil || !c.skip(c.Posting().Index) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Cursor) next() bool {
+	if c.decoder != nil {
+		return c.decoder.Next()
+	}
+
+	if c.pos < len(c.postings) {
+		c.pos++
+	}
+	return c.pos < len(c.postings)
+}
+
+// Advance moves to the first posting with index at least target, unless the current one already is.
+// It returns false if there's no such posting.
+func (c *Cursor) Advance(target int32) bool {
+	if !c.advance(target) {
+		return false
+	}
+	if c.skip != nil && c.skip(c.Posting().Index) {
+		return c.Next()
+	}
+	return true
+}
+
+func (c *Cursor) advance(target int32) bool {
+	if c.decoder != nil {
+		return c.decoder.Advance(target)
+	}
+
+	if c.pos >= 0 && c.pos < len(c.postings) && c.postings[c.pos].Index >= target {
+		return true
+	}
+
+	// gallop until a posting which isn't before target and then search between the last two steps
+	low := c.pos + 1
+	high := low
+	for step := 1; high < len(c.postings) && c.postings[high].Index < target; step *= 2 {
+		low = high + 1
+		high += step
+	}
+	if high > len(c.postings) {
+		high = len(c.postings)
+	}
+
+	c.pos = low + sort.Search(high-low, func(k int) bool {
+		return c.postings[low+k].Index >= target
+	})
+	return c.pos < len(c.postings)
+}
+
+// Posting returns the current posting, it's only valid until the cursor moves
+func (c *Cursor) Posting() *Posting {
+	if c.decoder != nil {
+		return c.decoder.Posting()
+	}
+	return &c.postings[c.pos]
+}
@@ -0,0 +1,109 @@
+package indices
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns cursors over the same postings, the first one compact and the second one encoded
+func makeCursors(postings []Posting) (*Cursor, *Cursor) {
+	compact := make([]Posting, len(postings))
+	copy(compact, postings)
+
+	return &Cursor{postings: compact, pos: -1}, &Cursor{decoder: NewPostingDecoder(EncodePostings(postings))}
+}
+
+func TestCursor_Next(t *testing.T) {
+	assert := assert.New(t)
+
+	postings := makePostings(3*BlockSize+5, true)
+	compact, encoded := makeCursors(postings)
+
+	for _, cursor := range []*Cursor{compact, encoded} {
+		assert.Equal(len(postings), cursor.Len())
+
+		for i := range postings {
+			assert.True(cursor.Next())
+			assert.Equal(postings[i], *cursor.Posting())
+		}
+		assert.False(cursor.Next())
+		assert.False(cursor.Next())
+	}
+}
+
+func TestCursor_Advance(t *testing.T) {
+	assert := assert.New(t)
+
+	postings := makePostings(5*BlockSize+17, false)
+	last := postings[len(postings)-1].Index
+
+	// the first posting with index at least target
+	expected := func(target int32) int32 {
+		for i := range postings {
+			if postings[i].Index >= target {
+				return postings[i].Index
+			}
+		}
+		return -1
+	}
+
+	for _, step := range []int32{1, 7, 50, 1000, 5000} {
+		compact, encoded := makeCursors(postings)
+
+		for target := int32(0); target <= last+step; target += step {
+			for _, cursor := range []*Cursor{compact, encoded} {
+				if expected(target) == -1 {
+					assert.False(cursor.Advance(target), "step %d, target %d", step, target)
+					continue
+				}
+
+				assert.True(cursor.Advance(target), "step %d, target %d", step, target)
+				assert.Equal(expected(target), cursor.Posting().Index, "step %d, target %d", step, target)
+			}
+		}
+	}
+}
+
+func TestCursor_AdvanceAndNext(t *testing.T) {
+	assert := assert.New(t)
+
+	postings := makePostings(2*BlockSize, false)
+	compact, encoded := makeCursors(postings)
+
+	for _, cursor := range []*Cursor{compact, encoded} {
+		// advancing to the current posting doesn't move
+		assert.True(cursor.Advance(postings[BlockSize+3].Index))
+		assert.True(cursor.Advance(postings[BlockSize].Index))
+		assert.Equal(postings[BlockSize+3].Index, cursor.Posting().Index)
+
+		assert.True(cursor.Next())
+		assert.Equal(postings[BlockSize+4].Index, cursor.Posting().Index)
+	}
+}
+
+func TestTermCursor(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar"))
+	ti.Add(makeDocument("foo", "qux"))
+
+	foo := ti.Dictionary.Find([]byte("foo"))
+
+	// the lists aren't compact before finalising
+	cursor := ti.TermCursor(foo)
+	assert.True(cursor.Advance(1))
+	assert.Equal(int32(2), cursor.Posting().Index)
+
+	ti.Finalise()
+	ti.Inverse.Compress()
+
+	cursor = ti.TermCursor(foo)
+	assert.True(cursor.Next())
+	assert.Equal(int32(0), cursor.Posting().Index)
+	assert.True(cursor.Advance(1))
+	assert.Equal(int32(2), cursor.Posting().Index)
+	assert.False(cursor.Next())
+}
@@ -0,0 +1,185 @@
+package indices
+
+import (
+	"fmt"
+
+	"github.com/bitterfly/search/trie"
+)
+
+// Len returns the number of documents which aren't deleted
+func (t *TotalIndex) Len() int {
+	return len(t.Documents) - t.NumDeleted
+}
+
+// DocumentFrequency returns the number of documents which aren't deleted and contain the term
+func (t *TotalIndex) DocumentFrequency(termID int32) int {
+	postingList := &t.Inverse.PostingLists[termID]
+	return postingList.Len - postingList.Deleted
+}
+
+// Delete marks the document as deleted. Its postings stay in the index until Compact,
+// but are skipped when looping over terms and with cursors.
+// The stored score bounds are dropped, since document frequencies change
+func (t *TotalIndex) Delete(docID int32) error {
+	if docID < 0 || int(docID) >= len(t.Documents) {
+		return fmt.Errorf("no document %d", docID)
+	}
+	if t.Documents[docID].Deleted {
+		return fmt.Errorf("document %d is already deleted", docID)
+	}
+
+	t.Documents[docID].Deleted = true
+	t.NumDeleted++
+
+	t.Forward.loop(docID, func(posting *Posting) {
+		t.Inverse.PostingLists[posting.Index].Deleted++
+	})
+
+	t.MaxScores = nil
+	return nil
+}
+
+// Update deletes the document and adds its new version, which gets a new id
+func (t *TotalIndex) Update(docID int32, d *InfoAndTerms) (int32, error) {
+	err := t.Delete(docID)
+	if err != nil {
+		return -1, err
+	}
+
+	return t.Add(d), nil
+}
+
+// Compact rewrites the index without the deleted documents and the terms which are only in them.
+// The rest of the documents and terms keep their order, but get new ids without gaps.
+// So do the classes, which are dropped if only deleted documents had them.
+// It returns the new id of every old document and every old term, -1 for the dropped ones.
+// Whatever is kept by document id outside the index, like a document store, has to be
+// renumbered with them, see docstore.Store.Renumber
+func (t *TotalIndex) Compact() ([]int32, []int32) {
+	forwardEncoded := t.Forward.Encoded != nil
+	inverseEncoded := t.Inverse.Encoded != nil
+	for _, index := range []*Index{&t.Forward, &t.Inverse} {
+		err := index.Decompress()
+		if err != nil {
+			panic(fmt.Sprintf("unable to compact a compressed index: %s", err))
+		}
+	}
+
+	docIDs := make([]int32, len(t.Documents))
+	documents := make([]DocumentInfo, 0, t.Len())
+	for docID := range t.Documents {
+		if t.Documents[docID].Deleted {
+			docIDs[docID] = -1
+			continue
+		}
+		docIDs[docID] = int32(len(documents))
+		documents = append(documents, t.Documents[docID])
+	}
+
+	used := make([]bool, t.ClassNames.Size)
+	for _, doc := range documents {
+		for _, class := range doc.Classes {
+			used[class] = true
+		}
+	}
+	classIDs := make([]int32, len(used))
+	classNames := trie.NewBiDictionary()
+	for class := range used {
+		classIDs[class] = -1
+		if used[class] {
+			classIDs[class] = classNames.Get(t.ClassNames.GetInverse(int32(class)))
+		}
+	}
+	for docID := range documents {
+		classes := make([]int32, len(documents[docID].Classes))
+		for i, class := range documents[docID].Classes {
+			classes[i] = classIDs[class]
+		}
+		documents[docID].Classes = classes
+	}
+
+	termIDs := make([]int32, len(t.Inverse.PostingLists))
+	dictionary := trie.NewBiDictionary()
+	for termID := range t.Inverse.PostingLists {
+		if t.DocumentFrequency(int32(termID)) == 0 {
+			termIDs[termID] = -1
+			continue
+		}
+		termIDs[termID] = dictionary.Get(t.Dictionary.GetInverse(int32(termID)))
+	}
+
+	forward := Index{Compact: true}
+	for docID := range t.Documents {
+		if docIDs[docID] == -1 {
+			continue
+		}
+
+		var postings []Posting
+		t.Forward.loop(int32(docID), func(posting *Posting) {
+			postings = append(postings, *posting)
+			postings[len(postings)-1].Index = termIDs[posting.Index]
+		})
+		forward.appendList(postings)
+	}
+
+	inverse := Index{Compact: true}
+	for termID := range t.Inverse.PostingLists {
+		if termIDs[termID] == -1 {
+			continue
+		}
+
+		var postings []Posting
+		t.Inverse.loop(int32(termID), func(posting *Posting) {
+			if docIDs[posting.Index] != -1 {
+				postings = append(postings, *posting)
+				postings[len(postings)-1].Index = docIDs[posting.Index]
+			}
+		})
+		inverse.appendList(postings)
+	}
+
+	for c := range t.Centroids {
+		centroid := make([]float64, dictionary.Size)
+		for termID, x := range t.Centroids[c] {
+			if termID < len(termIDs) && termIDs[termID] != -1 {
+				centroid[termIDs[termID]] = x
+			}
+		}
+		t.Centroids[c] = centroid
+	}
+
+	t.Documents = documents
+	t.Dictionary = *dictionary
+	t.ClassNames = *classNames
+	t.Forward = forward
+	t.Inverse = inverse
+	t.NumDeleted = 0
+	t.MaxScores = nil
+
+	if forwardEncoded {
+		t.Forward.Compress()
+	}
+	if inverseEncoded {
+		t.Inverse.Compress()
+	}
+
+	return docIDs, termIDs
+}
+
+// Adds the postings as the next list of a compact index
+func (i *Index) appendList(postings []Posting) {
+	postingList := PostingList{FirstIndex: -1, LastIndex: -1, Len: len(postings)}
+
+	if len(postings) > 0 {
+		postingList.FirstIndex = int32(len(i.Postings))
+		postingList.LastIndex = int32(len(i.Postings) + len(postings) - 1)
+
+		for p := range postings {
+			postings[p].NextPostingIndex = int32(len(i.Postings) + p + 1)
+		}
+		postings[len(postings)-1].NextPostingIndex = -1
+	}
+
+	i.Postings = append(i.Postings, postings...)
+	i.PostingLists = append(i.PostingLists, postingList)
+}
@@ -0,0 +1,130 @@
+package indices
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func cursorDocuments(ti *TotalIndex, term string) []int32 {
+	var docs []int32
+	cursor := ti.TermCursor(ti.Dictionary.Find([]byte(term)))
+	for cursor.Next() {
+		docs = append(docs, cursor.Posting().Index)
+	}
+	return docs
+}
+
+func TestDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar", "qux"))
+	ti.Add(makeDocument("foo", "qux", "qux"))
+	ti.Finalise()
+
+	foo := ti.Dictionary.Find([]byte("foo"))
+	qux := ti.Dictionary.Find([]byte("qux"))
+	ti.MaxScores = map[string][]float64{"tfidf": {1, 2, 3}}
+
+	assert.Nil(ti.Delete(2))
+	assert.NotNil(ti.Delete(2))
+	assert.NotNil(ti.Delete(3))
+	assert.Empty(ti.Verify())
+
+	assert.Nil(ti.MaxScores)
+	assert.Equal(2, ti.Len())
+	assert.Equal(1, ti.DocumentFrequency(foo))
+	assert.Equal(1, ti.DocumentFrequency(qux))
+
+	assert.Equal([]int32{0}, termDocuments(ti, "foo"))
+	assert.Equal([]int32{1}, termDocuments(ti, "qux"))
+	assert.Equal([]int32{0}, cursorDocuments(ti, "foo"))
+
+	cursor := ti.TermCursor(qux)
+	assert.False(cursor.Advance(2))
+
+	// the postings are still there until compaction
+	assert.Len(ti.TermPostings(foo), 2)
+}
+
+func TestUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar", "qux"))
+
+	docID, err := ti.Update(0, makeDocument("foo", "baz"))
+	assert.Nil(err)
+	assert.Equal(int32(2), docID)
+	assert.True(ti.Documents[0].Deleted)
+	assert.Equal(2, ti.Len())
+
+	assert.Equal([]int32{2}, termDocuments(ti, "foo"))
+	assert.Equal([]int32{1}, termDocuments(ti, "bar"))
+	assert.Equal([]int32{2}, termDocuments(ti, "baz"))
+
+	ti.Finalise()
+	assert.Empty(ti.Verify())
+
+	_, err = ti.Update(0, makeDocument("foo"))
+	assert.NotNil(err)
+}
+
+func TestCompact(t *testing.T) {
+	for _, compressed := range []bool{false, true} {
+		assert := assert.New(t)
+
+		ti := NewTotalIndex()
+		for i, terms := range [][]string{{"foo", "bar"}, {"baz", "qux"}, {"foo", "qux", "qux"}, {"bar"}} {
+			doc := makeDocument(terms...)
+			doc.Classes = [][]string{{"crude"}, {"gone"}, {"grain", "crude"}, {"ship"}}[i]
+			ti.Add(doc)
+		}
+		ti.Finalise()
+		ti.Centroids = [][]float64{{1, 2, 3, 4}}
+
+		if compressed {
+			ti.Forward.Compress()
+			ti.Inverse.Compress()
+		}
+
+		assert.Nil(ti.Delete(1))
+		assert.Nil(ti.Delete(3))
+
+		docIDs, termIDs := ti.Compact()
+		assert.Empty(ti.Verify())
+
+		assert.Equal([]int32{0, -1, 1, -1}, docIDs)
+		// baz is only in a deleted document
+		assert.Equal([]int32{0, 1, -1, 2}, termIDs)
+		assert.Equal(compressed, ti.Inverse.Encoded != nil)
+		assert.Equal(compressed, ti.Forward.Encoded != nil)
+
+		assert.Equal(0, ti.NumDeleted)
+		assert.Len(ti.Documents, 2)
+		assert.Equal(int32(3), ti.Dictionary.Size)
+		assert.Equal(int32(-1), ti.Dictionary.Find([]byte("baz")))
+		assert.Equal([]float64{1, 2, 4}, ti.Centroids[0])
+
+		// gone and ship are only in deleted documents
+		assert.Equal(int32(2), ti.ClassNames.Size)
+		assert.Equal([]byte("crude"), ti.ClassNames.GetInverse(0))
+		assert.Equal([]byte("grain"), ti.ClassNames.GetInverse(1))
+		assert.Equal(int32(-1), ti.ClassNames.Find([]byte("gone")))
+		assert.Equal([]int32{0}, ti.Documents[0].Classes)
+		assert.Equal([]int32{1, 0}, ti.Documents[1].Classes)
+
+		assert.Equal([]int32{0, 1}, termDocuments(ti, "foo"))
+		assert.Equal([]int32{0}, termDocuments(ti, "bar"))
+		assert.Equal([]int32{1}, termDocuments(ti, "qux"))
+
+		var terms []int32
+		ti.LoopOverDocumentPostings(1, func(posting *Posting) {
+			terms = append(terms, posting.Index)
+		})
+		assert.Equal([]int32{0, 2}, terms)
+	}
+}
@@ -15,6 +15,10 @@ type Posting struct {
 	Count           int32
 	NormalisedCount float32
 
+	// Positions of the term in the document, counted in terms (stop words are skipped).
+	// Only kept in the inverse index, forward postings leave it empty
+	Positions []int32
+
 	NextPostingIndex int32
 }
 
@@ -22,11 +26,21 @@ type PostingList struct {
 	FirstIndex int32
 	LastIndex  int32
 	Len        int
+
+	// Number of postings of deleted documents in the list, which are only dropped by Compact
+	Deleted int
 }
 
+// While building, the postings of a list are linked through NextPostingIndex and
+// can be scattered around Postings. After Finalise every list is the contiguous range
+// Postings[FirstIndex : FirstIndex+Len] (the links are kept valid, so more documents
+// can be added, which makes the index non compact again).
+// After Compress the lists are only kept encoded in Encoded, see EncodePostings
 type Index struct {
 	PostingLists []PostingList
 	Postings     []Posting
+	Compact      bool
+	Encoded      [][]byte
 }
 
 type TotalIndex struct {
@@ -36,6 +50,14 @@ type TotalIndex struct {
 	Dictionary trie.BiDictionary // bidictionary is better for debugging
 	ClassNames trie.BiDictionary
 	Centroids  [][]float64
+
+	// Number of documents marked as deleted
+	NumDeleted int
+
+	// For every scorer name the highest score contribution of each term, see ranking.StoreMaxScores
+	MaxScores map[string][]float64
+
+	Metadata Metadata
 }
 
 type DocumentInfo struct {
@@ -44,19 +66,86 @@ type DocumentInfo struct {
 	Length       int32
 	UniqueLength int32
 	ClusterID    int
+	Deleted      bool
 }
 
 func NewTotalIndex() *TotalIndex {
 	return &TotalIndex{
+		Forward:    Index{Compact: true},
+		Inverse:    Index{Compact: true},
 		Dictionary: *trie.NewBiDictionary(),
 		ClassNames: *trie.NewBiDictionary(),
 	}
 }
 
+// Postings of deleted documents are skipped. The postings of a compressed index are decoded copies,
+// so changes to them are lost, Decompress it first to change them
 func (t *TotalIndex) LoopOverTermPostings(termID int32, operation func(posting *Posting)) {
-	postingList := &t.Inverse.PostingLists[termID]
+	if t.NumDeleted == 0 {
+		t.Inverse.loop(termID, operation)
+		return
+	}
+
+	t.Inverse.loop(termID, func(posting *Posting) {
+		if !t.Documents[posting.Index].Deleted {
+			operation(posting)
+		}
+	})
+}
+
+// Like LoopOverTermPostings, the postings of a compressed index are copies
+func (t *TotalIndex) LoopOverDocumentPostings(docID int32, operation func(posting *Posting)) {
+	t.Forward.loop(docID, operation)
+}
+
+// Returns the postings of the term, sorted by document id, including the ones of deleted documents.
+// The index must be finalised
+func (t *TotalIndex) TermPostings(termID int32) []Posting {
+	return t.Inverse.List(termID)
+}
+
+// Returns the postings of the document, sorted by term id. The index must be finalised
+func (t *TotalIndex) DocumentPostings(docID int32) []Posting {
+	return t.Forward.List(docID)
+}
+
+// Finalise compacts both the forward and the inverse index and freezes the dictionary.
+// Documents can still be added after it, but Finalise has to be called again
+func (t *TotalIndex) Finalise() {
+	t.Forward.Finalise()
+	t.Inverse.Finalise()
+	t.Dictionary.Freeze()
+}
+
+func (i *Index) loop(listID int32, operation func(posting *Posting)) {
+	if i.Encoded != nil {
+		decoder := NewPostingDecoder(i.Encoded[listID])
+		for decoder.Next() {
+			// copied, so that the posting can outlive the iteration
+			posting := *decoder.Posting()
+			operation(&posting)
+		}
+		if decoder.Err() != nil {
+			panic(fmt.Sprintf("unable to decode list %d: %s", listID, decoder.Err()))
+		}
+		return
+	}
+
+	postingList := &i.PostingLists[listID]
+
+	if postingList.FirstIndex == -1 {
+		return
+	}
+
+	if i.Compact {
+		postings := i.Postings[postingList.FirstIndex : int(postingList.FirstIndex)+postingList.Len]
+		for p := range postings {
+			operation(&postings[p])
+		}
+		return
+	}
 
-	for posting := &t.Inverse.Postings[postingList.FirstIndex]; ; posting = &t.Inverse.Postings[posting.NextPostingIndex] {
+	for posting := &i.Postings[postingList.FirstIndex]; ; posting = &i.Postings[posting.NextPostingIndex] {
 		operation(posting)
 
 		if posting.NextPostingIndex == -1 {
@@ -65,26 +154,88 @@ func (t *TotalIndex) LoopOverTermPostings(termID int32, operation func(posting *
 	}
 }
 
-func (t *TotalIndex) LoopOverDocumentPostings(docID int32, operation func(posting *Posting)) {
-	postingList := &t.Forward.PostingLists[docID]
+// List returns the postings of the list as a slice of the index
+func (i *Index) List(listID int32) []Posting {
+	if !i.Compact {
+		panic("posting lists can only be sliced after the index is finalised")
+	}
 
+	postingList := &i.PostingLists[listID]
 	if postingList.FirstIndex == -1 {
-		fmt.Printf("DocId has first index -1: %d, Postinglist: %v\n", docID, postingList)
+		return nil
+	}
+
+	return i.Postings[postingList.FirstIndex : int(postingList.FirstIndex)+postingList.Len]
+}
+
+// Finalise rewrites the postings so that every list is contiguous and the lists are in order.
+// Postings which aren't reachable from any list are dropped
+func (i *Index) Finalise() {
+	if i.Compact || i.Encoded != nil {
 		return
 	}
 
-	for posting := &t.Forward.Postings[postingList.FirstIndex]; ; posting = &t.Forward.Postings[posting.NextPostingIndex] {
-		operation(posting)
-		if posting.NextPostingIndex == -1 {
-			break
+	postings := make([]Posting, 0, len(i.Postings))
+
+	for listID := range i.PostingLists {
+		postingList := &i.PostingLists[listID]
+		if postingList.FirstIndex == -1 {
+			postingList.LastIndex = -1
+			postingList.Len = 0
+			continue
 		}
+
+		first := int32(len(postings))
+		for index := postingList.FirstIndex; index != -1; index = i.Postings[index].NextPostingIndex {
+			postings = append(postings, i.Postings[index])
+			postings[len(postings)-1].NextPostingIndex = int32(len(postings))
+		}
+		postings[len(postings)-1].NextPostingIndex = -1
+
+		postingList.FirstIndex = first
+		postingList.LastIndex = int32(len(postings) - 1)
+		postingList.Len = len(postings) - int(first)
 	}
+
+	i.Postings = postings
+	i.Compact = true
+}
+
+// Counts the postings of every list again by following it. Indices serialised before there was
+// a header may have been built when Add counted the first posting of a new term as 0, so their
+// inverse lists are one too short
+func (i *Index) recountLengths() {
+	if i.Encoded != nil {
+		return
+	}
+
+	for listID := range i.PostingLists {
+		postingList := &i.PostingLists[listID]
+		postingList.Len = 0
+		// a corrupt list could loop, Verify reports it
+		for index := postingList.FirstIndex; index >= 0 && int(index) < len(i.Postings) && postingList.Len <= len(i.Postings); index = i.Postings[index].NextPostingIndex {
+			postingList.Len++
+		}
+	}
+}
+
+// Inverse document frequency of the term, smoothed so that it's defined
+// for terms which don't occur in any document
+func (t *TotalIndex) IDF(termID int32) float64 {
+	return IDF(t, termID)
 }
 
+// SerialiseTo writes a header with the metadata followed by the index
 func (t *TotalIndex) SerialiseTo(w io.Writer) error {
+	t.updateMetadata()
+
 	gzWriter := gzip.NewWriter(w)
 	encoder := gob.NewEncoder(gzWriter)
-	err := encoder.Encode(t)
+	err := encoder.Encode(&header{Magic: headerMagic, Metadata: t.Metadata})
+	if err != nil {
+		return err
+	}
+	err = encoder.Encode(t)
 	if err != nil {
 		return err
 	}
@@ -99,14 +250,38 @@ func (t *TotalIndex) SerialiseToFile(filename string) error {
 	return t.SerialiseTo(f)
 }
 
+// DeserialiseFrom reads an index written by SerialiseTo.
+// Indices from before the header are still read, their metadata is empty.
+// Indices in a newer format are refused.
 func (t *TotalIndex) DeserialiseFrom(r io.Reader) error {
 	gzReader, err := gzip.NewReader(r)
 	if err != nil {
 		return err
 	}
 
-	decoder := gob.NewDecoder(gzReader)
-	err = decoder.Decode(t)
+	reader := &recordingReader{reader: gzReader, recording: true}
+	decoder := gob.NewDecoder(reader)
+
+	var h header
+	err = decoder.Decode(&h)
+	reader.recording = false
+
+	if err == nil && h.Magic != "" {
+		err = checkHeader(&h)
+		if err != nil {
+			return err
+		}
+		err = decoder.Decode(t)
+	} else {
+		// a legacy index starts right away, so start again with what's been read so far
+		decoder = gob.NewDecoder(io.MultiReader(&reader.recorded, gzReader))
+		err = decoder.Decode(t)
+		t.Metadata = Metadata{}
+		if err == nil {
+			t.Forward.recountLengths()
+			t.Inverse.recountLengths()
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -114,8 +289,34 @@ func (t *TotalIndex) DeserialiseFrom(r io.Reader) error {
 	return gzReader.Close()
 }
 
+// ReadMetadata only decodes the header of a serialised index
+func ReadMetadata(r io.Reader) (Metadata, error) {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var h header
+	if gob.NewDecoder(gzReader).Decode(&h) != nil || h.Magic == "" {
+		// a legacy index
+		return Metadata{}, nil
+	}
+
+	return h.Metadata, checkHeader(&h)
+}
+
 func (t *TotalIndex) Normalise() {
-	for docId := int32(0); docId < int32(len(t.Forward.PostingLists)); docId++ {
+	t.NormaliseFrom(0)
+}
+
+// NormaliseFrom normalises only the documents from docID on, e.g. the ones appended to a normalised index
+func (t *TotalIndex) NormaliseFrom(docID int32) {
+	err := t.Forward.Decompress()
+	if err != nil {
+		panic(fmt.Sprintf("unable to normalise a compressed index: %s", err))
+	}
+
+	for docId := docID; docId < int32(len(t.Forward.PostingLists)); docId++ {
 		normalise := func(posting *Posting) {
 			if t.Documents[docId].UniqueLength != 0 {
 				posting.NormalisedCount = float32(posting.Count) / float32(t.Documents[docId].UniqueLength)
@@ -133,41 +334,3 @@ func (t *TotalIndex) DeserialiseFromFile(filename string) error {
 	}
 	return t.DeserialiseFrom(f)
 }
-
-func (t *TotalIndex) Verify() {
-	for docID := range t.Forward.PostingLists {
-		var lastPosting *Posting
-		t.LoopOverDocumentPostings(int32(docID), func(posting *Posting) {
-			if lastPosting != nil {
-				if posting.Index <= lastPosting.Index {
-					panic(fmt.Sprintf(
-						"consecutive postings of document %d have out of order term indices: %d, %d",
-						docID, lastPosting.Index, posting.Index,
-					))
-				}
-			}
-			lastPosting = posting
-		})
-		if lastPosting == nil {
-			panic(fmt.Sprintf("document %d has no terms", docID))
-		}
-	}
-
-	for termID := range t.Inverse.PostingLists {
-		var lastPosting *Posting
-		t.LoopOverTermPostings(int32(termID), func(posting *Posting) {
-			if lastPosting != nil {
-				if posting.Index <= lastPosting.Index {
-					panic(fmt.Sprintf(
-						"consecutive postings of term %d have out of order document indices: %d, %d",
-						termID, lastPosting.Index, posting.Index,
-					))
-				}
-			}
-			lastPosting = posting
-		})
-		if lastPosting == nil {
-			panic(fmt.Sprintf("term %d has no documents", termID))
-		}
-	}
-}
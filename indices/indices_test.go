@@ -0,0 +1,98 @@
+package indices
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialise(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := NewInfoAndTerms()
+	doc.Name = "doc0"
+	doc.Classes = []string{"sports"}
+	for _, term := range []string{"foo", "bar", "foo"} {
+		doc.AddTerm(term)
+	}
+
+	ti := NewTotalIndex()
+	ti.Add(doc)
+
+	var buffer bytes.Buffer
+	assert.Nil(ti.SerialiseTo(&buffer))
+
+	loaded := NewTotalIndex()
+	assert.Nil(loaded.DeserialiseFrom(&buffer))
+
+	assert.Equal(ti.Documents, loaded.Documents)
+	assert.Equal(ti.Inverse, loaded.Inverse)
+	assert.Equal(ti.Forward, loaded.Forward)
+	assert.Equal(ti.Dictionary.Find([]byte("foo")), loaded.Dictionary.Find([]byte("foo")))
+
+	foo := loaded.Inverse.Postings[loaded.Inverse.PostingLists[loaded.Dictionary.Find([]byte("foo"))].FirstIndex]
+	assert.Equal([]int32{0, 2}, foo.Positions)
+}
+
+func makeDocument(terms ...string) *InfoAndTerms {
+	doc := NewInfoAndTerms()
+	for _, term := range terms {
+		doc.AddTerm(term)
+	}
+	return doc
+}
+
+func termDocuments(ti *TotalIndex, term string) []int32 {
+	var docs []int32
+	ti.LoopOverTermPostings(ti.Dictionary.Find([]byte(term)), func(posting *Posting) {
+		docs = append(docs, posting.Index)
+	})
+	return docs
+}
+
+func TestFinalise(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar", "qux"))
+	ti.Add(makeDocument("foo", "qux", "qux"))
+
+	// the postings of foo are interleaved with the others
+	assert.False(ti.Inverse.Compact)
+	assert.True(ti.Forward.Compact)
+
+	ti.Finalise()
+	assert.True(ti.Inverse.Compact)
+	assert.Empty(ti.Verify())
+
+	foo := ti.TermPostings(ti.Dictionary.Find([]byte("foo")))
+	assert.Len(foo, 2)
+	assert.Equal(int32(0), foo[0].Index)
+	assert.Equal(int32(2), foo[1].Index)
+
+	qux := ti.TermPostings(ti.Dictionary.Find([]byte("qux")))
+	assert.Equal(int32(2), qux[1].Count)
+	assert.Equal([]int32{1, 2}, qux[1].Positions)
+
+	assert.Equal([]int32{0, 2}, termDocuments(ti, "foo"))
+	assert.Equal([]int32{0, 1}, termDocuments(ti, "bar"))
+	assert.Equal([]int32{1, 2}, termDocuments(ti, "qux"))
+
+	doc := ti.DocumentPostings(2)
+	assert.Len(doc, 2)
+	assert.Equal(ti.Dictionary.Find([]byte("foo")), doc[0].Index)
+
+	// adding to a compact index keeps it usable through the links
+	ti.Add(makeDocument("foo", "baz"))
+	assert.False(ti.Inverse.Compact)
+	assert.Equal([]int32{0, 2, 3}, termDocuments(ti, "foo"))
+	assert.Equal([]int32{3}, termDocuments(ti, "baz"))
+	assert.Panics(func() { ti.TermPostings(0) })
+
+	ti.Finalise()
+	assert.Empty(ti.Verify())
+	assert.Equal([]int32{0, 2, 3}, termDocuments(ti, "foo"))
+	assert.Len(ti.Inverse.Postings, 8)
+}
@@ -0,0 +1,155 @@
+package indices
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/bitterfly/search/trie"
+)
+
+// Merge returns a new index with the documents of a followed by the documents of b.
+// The terms and classes of a keep their ids, the ones only in b are numbered after them.
+// Neither index can have deleted documents (see Compact) and both have to be tokenised the same way.
+// Like with Add, the documents of b aren't assigned to the clusters of a
+// and the stored score bounds are dropped
+func Merge(a, b *TotalIndex) (*TotalIndex, error) {
+	return mergeAll([]*TotalIndex{a, b})
+}
+
+// Merges the indices in order in one pass, see Merge
+func mergeAll(parts []*TotalIndex) (*TotalIndex, error) {
+	var tokenised *TotalIndex
+	for _, part := range parts {
+		if part.NumDeleted != 0 {
+			return nil, fmt.Errorf("index has %d deleted documents, it has to be compacted first", part.NumDeleted)
+		}
+
+		if part.Metadata.Tokeniser == "" {
+			continue
+		}
+		if tokenised == nil {
+			tokenised = part
+		} else if part.Metadata.Tokeniser != tokenised.Metadata.Tokeniser || part.Metadata.StopWordsHash != tokenised.Metadata.StopWordsHash {
+			return nil, fmt.Errorf(
+				"indices are tokenised differently: %s (stop words %s) and %s (stop words %s)",
+				tokenised.Metadata.Tokeniser, tokenised.Metadata.StopWordsHash,
+				part.Metadata.Tokeniser, part.Metadata.StopWordsHash,
+			)
+		}
+	}
+
+	merged := NewTotalIndex()
+	merged.Metadata = parts[0].Metadata
+	for _, part := range parts[1:] {
+		merged.Metadata = mergeMetadata(merged.Metadata, part.Metadata)
+	}
+	merged.Centroids = parts[0].Centroids
+
+	// the ids of the terms of every part in the merged index and back
+	termIDs := make([][]int32, len(parts))
+	classIDs := make([][]int32, len(parts))
+	for p, part := range parts {
+		termIDs[p] = mergeDictionary(&merged.Dictionary, &part.Dictionary)
+		classIDs[p] = mergeDictionary(&merged.ClassNames, &part.ClassNames)
+	}
+
+	partTermIDs := make([][]int32, len(parts))
+	for p := range parts {
+		partTermIDs[p] = make([]int32, merged.Dictionary.Size)
+		for termID := range partTermIDs[p] {
+			partTermIDs[p][termID] = -1
+		}
+		for termID, mergedID := range termIDs[p] {
+			partTermIDs[p][mergedID] = int32(termID)
+		}
+	}
+
+	offsets := make([]int32, len(parts))
+	length := 0
+	for p, part := range parts {
+		offsets[p] = int32(length)
+		length += len(part.Documents)
+	}
+
+	merged.Documents = make([]DocumentInfo, 0, length)
+	for p, part := range parts {
+		for _, doc := range part.Documents {
+			if p != 0 {
+				doc.ClusterID = -1
+			}
+
+			classes := make([]int32, len(doc.Classes))
+			for i, class := range doc.Classes {
+				classes[i] = classIDs[p][class]
+			}
+			doc.Classes = classes
+
+			merged.Documents = append(merged.Documents, doc)
+		}
+	}
+
+	for p, part := range parts {
+		for docID := range part.Documents {
+			var postings []Posting
+			part.Forward.loop(int32(docID), func(posting *Posting) {
+				postings = append(postings, *posting)
+				postings[len(postings)-1].Index = termIDs[p][posting.Index]
+			})
+			if p != 0 {
+				sort.Slice(postings, func(i, j int) bool { return postings[i].Index < postings[j].Index })
+			}
+			merged.Forward.appendList(postings)
+		}
+	}
+
+	for termID := int32(0); termID < merged.Dictionary.Size; termID++ {
+		var postings []Posting
+		for p, part := range parts {
+			partTermID := partTermIDs[p][termID]
+			if partTermID == -1 || int(partTermID) >= len(part.Inverse.PostingLists) {
+				continue
+			}
+
+			part.Inverse.loop(partTermID, func(posting *Posting) {
+				postings = append(postings, *posting)
+				postings[len(postings)-1].Index += offsets[p]
+			})
+		}
+		merged.Inverse.appendList(postings)
+	}
+
+	return merged, nil
+}
+
+// Adds the strings of the dictionary to the merged one, strings which aren't in it yet
+// are numbered in the order of the dictionary. Returns the new id of every id of the dictionary
+func mergeDictionary(merged, dictionary *trie.BiDictionary) []int32 {
+	ids := make([]int32, dictionary.Size)
+	for id := range ids {
+		ids[id] = merged.Get(dictionary.GetInverse(int32(id)))
+	}
+	return ids
+}
+
+// The tokeniser is only kept if both were tokenised with it,
+// the documents of an index without one may have been tokenised any other way
+func mergeMetadata(a, b Metadata) Metadata {
+	metadata := a
+	if a.Tokeniser != b.Tokeniser || a.StopWordsHash != b.StopWordsHash {
+		metadata.Tokeniser = ""
+		metadata.StopWordsHash = ""
+	}
+
+	metadata.Created = time.Time{}
+	metadata.CodeVersion = ""
+	if a.Source == "" {
+		metadata.Source = b.Source
+	} else if b.Source != "" && b.Source != a.Source {
+		metadata.Source = a.Source + ", " + b.Source
+	}
+	metadata.Classy = a.Classy || b.Classy
+	metadata.Classless = a.Classless || b.Classless
+
+	return metadata
+}
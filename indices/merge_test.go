@@ -0,0 +1,90 @@
+package indices
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	a := NewTotalIndex()
+	doc := makeDocument("foo", "bar")
+	doc.Classes = []string{"sports"}
+	a.Add(doc)
+	a.Add(makeDocument("bar", "qux", "bar"))
+	a.Finalise()
+	a.Normalise()
+	a.Metadata.Tokeniser = "test"
+	a.Metadata.Source = "a"
+	a.Centroids = [][]float64{{1, 2, 3}}
+	a.Documents[0].ClusterID = 0
+	a.MaxScores = map[string][]float64{"bm25": {1, 2, 3}}
+
+	b := NewTotalIndex()
+	doc = makeDocument("new", "foo")
+	doc.Classes = []string{"politics", "sports"}
+	b.Add(doc)
+	b.Add(makeDocument("qux"))
+	b.Finalise()
+	b.Normalise()
+	b.Inverse.Compress()
+	b.Metadata.Source = "b"
+
+	merged, err := Merge(a, b)
+	assert.Nil(err)
+	assert.Empty(merged.Verify())
+	assert.Nil(merged.MaxScores)
+
+	assert.Len(merged.Documents, 4)
+	assert.Equal(int32(4), merged.Dictionary.Size)
+	for _, term := range []string{"foo", "bar", "qux"} {
+		assert.Equal(a.Dictionary.Find([]byte(term)), merged.Dictionary.Find([]byte(term)))
+	}
+	assert.Equal(int32(3), merged.Dictionary.Find([]byte("new")))
+
+	assert.Equal([]int32{0, 2}, termDocuments(merged, "foo"))
+	assert.Equal([]int32{0, 1}, termDocuments(merged, "bar"))
+	assert.Equal([]int32{1, 3}, termDocuments(merged, "qux"))
+	assert.Equal([]int32{2}, termDocuments(merged, "new"))
+
+	bar := merged.TermPostings(merged.Dictionary.Find([]byte("bar")))
+	assert.Equal([]int32{0, 2}, bar[1].Positions)
+
+	// the forward postings of b are sorted by the new term ids
+	doc2 := merged.DocumentPostings(2)
+	assert.Equal(merged.Dictionary.Find([]byte("foo")), doc2[0].Index)
+	assert.Equal(merged.Dictionary.Find([]byte("new")), doc2[1].Index)
+	assert.Equal(float32(0.5), doc2[0].NormalisedCount)
+
+	var classes []string
+	for _, class := range merged.Documents[2].Classes {
+		classes = append(classes, string(merged.ClassNames.GetInverse(class)))
+	}
+	assert.Equal([]string{"politics", "sports"}, classes)
+	assert.Equal(merged.Documents[0].Classes[0], merged.ClassNames.Find([]byte("sports")))
+
+	// the documents of b aren't clustered yet
+	assert.Equal(a.Centroids, merged.Centroids)
+	assert.Equal(0, merged.Documents[0].ClusterID)
+	assert.Equal(-1, merged.Documents[2].ClusterID)
+
+	// b may have been tokenised any way
+	assert.Empty(merged.Metadata.Tokeniser)
+	assert.Equal("a, b", merged.Metadata.Source)
+
+	b.Metadata.Tokeniser = "test"
+	merged, err = Merge(a, b)
+	assert.Nil(err)
+	assert.Equal("test", merged.Metadata.Tokeniser)
+
+	b.Metadata.Tokeniser = "other"
+	_, err = Merge(a, b)
+	assert.NotNil(err)
+
+	b.Metadata.Tokeniser = ""
+	assert.Nil(a.Delete(0))
+	_, err = Merge(a, b)
+	assert.NotNil(err)
+}
@@ -0,0 +1,107 @@
+package indices
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"time"
+)
+
+// FormatVersion is the version of the serialised index.
+// Indices serialised before there was a header have version 0,
+// from version 2 the dictionary can be serialised as a trie.DoubleArray.
+const FormatVersion = 2
+
+// Written before the index, so that the metadata can be read without decoding the whole index
+type header struct {
+	Magic    string
+	Metadata Metadata
+}
+
+const headerMagic = "bitterfly/search index"
+
+// Metadata describes how an index was built
+type Metadata struct {
+	FormatVersion int
+	Created       time.Time
+	// Version of the module which serialised the index
+	CodeVersion string
+
+	// Name of the tokeniser and hash of its stop word list, see processing.Describe
+	Tokeniser     string
+	StopWordsHash string
+
+	// Where the documents came from and which ones were indexed
+	Source    string
+	Classy    bool
+	Classless bool
+
+	Documents int
+	Terms     int
+}
+
+func (m Metadata) String() string {
+	if m.FormatVersion == 0 {
+		return "no metadata (legacy index)"
+	}
+
+	return fmt.Sprintf(
+		"format %d, created %s by %s, tokeniser %s (stop words %s), source %s (classy %t, classless %t), %d documents, %d terms",
+		m.FormatVersion, m.Created.Format(time.RFC3339), m.CodeVersion,
+		m.Tokeniser, m.StopWordsHash, m.Source, m.Classy, m.Classless,
+		m.Documents, m.Terms,
+	)
+}
+
+func codeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	return info.Main.Path + "@" + info.Main.Version
+}
+
+// Update fills in what's known when an index with the given number of documents and terms is written
+func (m *Metadata) Update(documents int, terms int) {
+	m.FormatVersion = FormatVersion
+	if m.Created.IsZero() {
+		m.Created = time.Now()
+	}
+	if m.CodeVersion == "" {
+		m.CodeVersion = codeVersion()
+	}
+	m.Documents = documents
+	m.Terms = terms
+}
+
+// Fills in what's known at serialisation time
+func (t *TotalIndex) updateMetadata() {
+	t.Metadata.Update(len(t.Documents), int(t.Dictionary.Size))
+}
+
+// Records what is read from the underlying reader until it's stopped,
+// so that a legacy index can be decoded again from the start
+type recordingReader struct {
+	reader    io.Reader
+	recorded  bytes.Buffer
+	recording bool
+}
+
+func (r *recordingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if r.recording {
+		r.recorded.Write(p[:n])
+	}
+	return n, err
+}
+
+func checkHeader(h *header) error {
+	if h.Magic != headerMagic {
+		return fmt.Errorf("not an index")
+	}
+	if h.Metadata.FormatVersion > FormatVersion {
+		return fmt.Errorf("index format %d is newer than the supported %d", h.Metadata.FormatVersion, FormatVersion)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package indices
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/gob"
+	"testing"
+
+	"github.com/bitterfly/search/trie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSerialise_Metadata(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar"))
+	ti.Metadata.Tokeniser = "english"
+	ti.Metadata.StopWordsHash = "abc"
+	ti.Metadata.Classy = true
+
+	var buffer bytes.Buffer
+	assert.Nil(ti.SerialiseTo(&buffer))
+	serialised := buffer.Bytes()
+
+	metadata, err := ReadMetadata(bytes.NewReader(serialised))
+	assert.Nil(err)
+	assert.Equal(FormatVersion, metadata.FormatVersion)
+	assert.Equal("english", metadata.Tokeniser)
+	assert.Equal("abc", metadata.StopWordsHash)
+	assert.True(metadata.Classy)
+	assert.False(metadata.Classless)
+	assert.Equal(2, metadata.Documents)
+	assert.Equal(2, metadata.Terms)
+	assert.False(metadata.Created.IsZero())
+
+	loaded := NewTotalIndex()
+	assert.Nil(loaded.DeserialiseFrom(bytes.NewReader(serialised)))
+	assert.Equal(metadata.Tokeniser, loaded.Metadata.Tokeniser)
+	assert.True(metadata.Created.Equal(loaded.Metadata.Created))
+	assert.Equal(len(ti.Documents), len(loaded.Documents))
+}
+
+// How indices were serialised before the header
+type legacyIndex struct {
+	Forward    Index
+	Inverse    Index
+	Documents  []DocumentInfo
+	Dictionary trie.BiDictionary
+	ClassNames trie.BiDictionary
+	Centroids  [][]float64
+}
+
+func TestDeserialise_Legacy(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar"))
+
+	// the first posting of a term wasn't counted back then
+	inverse := ti.Inverse
+	inverse.PostingLists = append([]PostingList(nil), ti.Inverse.PostingLists...)
+	for listID := range inverse.PostingLists {
+		inverse.PostingLists[listID].Len--
+	}
+
+	var buffer bytes.Buffer
+	gzWriter := gzip.NewWriter(&buffer)
+	assert.Nil(gob.NewEncoder(gzWriter).Encode(&legacyIndex{
+		Forward:    ti.Forward,
+		Inverse:    inverse,
+		Documents:  ti.Documents,
+		Dictionary: ti.Dictionary,
+		ClassNames: ti.ClassNames,
+	}))
+	assert.Nil(gzWriter.Close())
+	serialised := buffer.Bytes()
+
+	metadata, err := ReadMetadata(bytes.NewReader(serialised))
+	assert.Nil(err)
+	assert.Equal(0, metadata.FormatVersion)
+
+	loaded := NewTotalIndex()
+	assert.Nil(loaded.DeserialiseFrom(bytes.NewReader(serialised)))
+	assert.Equal(0, loaded.Metadata.FormatVersion)
+	assert.Equal(len(ti.Documents), len(loaded.Documents))
+	assert.Equal(ti.Inverse, loaded.Inverse)
+	assert.Equal([]int32{0, 1}, termDocuments(loaded, "bar"))
+	assert.Equal(2, loaded.DocumentFrequency(loaded.Dictionary.Find([]byte("bar"))))
+	assert.Empty(loaded.Verify())
+}
+
+func TestDeserialise_Newer(t *testing.T) {
+	assert := assert.New(t)
+
+	var buffer bytes.Buffer
+	gzWriter := gzip.NewWriter(&buffer)
+	encoder := gob.NewEncoder(gzWriter)
+	assert.Nil(encoder.Encode(&header{Magic: headerMagic, Metadata: Metadata{FormatVersion: FormatVersion + 1}}))
+	assert.Nil(encoder.Encode(NewTotalIndex()))
+	assert.Nil(gzWriter.Close())
+
+	assert.NotNil(NewTotalIndex().DeserialiseFrom(bytes.NewReader(buffer.Bytes())))
+
+	_, err := ReadMetadata(bytes.NewReader(buffer.Bytes()))
+	assert.NotNil(err)
+}
@@ -0,0 +1,81 @@
+package indices
+
+import (
+	"fmt"
+	"math"
+)
+
+// Searchable is what queries and ranking read from an index. Besides TotalIndex it's implemented
+// by the memory mapped diskindex.Index, which only decodes the lists and documents asked for
+type Searchable interface {
+	// Len returns the number of documents which aren't deleted
+	Len() int
+	// NumDocuments returns the number of document ids, deleted documents included
+	NumDocuments() int
+	// NumTerms returns the number of terms with posting lists
+	NumTerms() int
+
+	// Find returns the id of the term or -1 if it's not in the dictionary
+	Find(term []byte) int32
+	// See trie.Trie.WalkPattern, the order of the terms depends on the index
+	WalkPattern(pattern []byte, operation func([]byte, int32))
+	// See trie.Trie.WalkFuzzy, the order of the terms depends on the index
+	WalkFuzzy(word []byte, maxDistance int, operation func([]byte, int32, int))
+
+	// DocumentFrequency returns the number of documents which aren't deleted and contain the term
+	DocumentFrequency(termID int32) int
+	// TermCursor returns a cursor over the documents containing the term, deleted documents are skipped
+	TermCursor(termID int32) *Cursor
+	// DocumentCursor returns a cursor over the terms in the document
+	DocumentCursor(docID int32) *Cursor
+
+	Document(docID int32) (DocumentInfo, error)
+	// Like Document, but without decoding its name and classes
+	DocumentLength(docID int32) int32
+	IsDeleted(docID int32) bool
+}
+
+// IDF is the inverse document frequency of the term, log(N / (1 + df))
+func IDF(index Searchable, termID int32) float64 {
+	return math.Log(float64(index.Len()) / float64(1+index.DocumentFrequency(termID)))
+}
+
+func (t *TotalIndex) NumDocuments() int {
+	return len(t.Documents)
+}
+
+func (t *TotalIndex) NumTerms() int {
+	return len(t.Inverse.PostingLists)
+}
+
+// Find returns the id of the term or -1 if it's not in the dictionary
+func (t *TotalIndex) Find(term []byte) int32 {
+	return t.Dictionary.Find(term)
+}
+
+func (t *TotalIndex) WalkPattern(pattern []byte, operation func([]byte, int32)) {
+	t.Dictionary.WalkPattern(pattern, operation)
+}
+
+func (t *TotalIndex) WalkFuzzy(word []byte, maxDistance int, operation func([]byte, int32, int)) {
+	t.Dictionary.WalkFuzzy(word, maxDistance, operation)
+}
+
+func (t *TotalIndex) DocumentCursor(docID int32) *Cursor {
+	return t.Forward.Cursor(docID)
+}
+
+func (t *TotalIndex) Document(docID int32) (DocumentInfo, error) {
+	if docID < 0 || int(docID) >= len(t.Documents) {
+		return DocumentInfo{}, fmt.Errorf("no document %d", docID)
+	}
+	return t.Documents[docID], nil
+}
+
+func (t *TotalIndex) DocumentLength(docID int32) int32 {
+	return t.Documents[docID].Length
+}
+
+func (t *TotalIndex) IsDeleted(docID int32) bool {
+	return t.Documents[docID].Deleted
+}
@@ -0,0 +1,279 @@
+package indices
+
+import (
+	"fmt"
+	"log"
+)
+
+// ListError is a problem with a posting list of the forward (by document) or the inverse (by term) index
+type ListError struct {
+	Index  string
+	ListID int32
+	Reason string
+}
+
+func (e *ListError) Error() string {
+	return fmt.Sprintf("%s list %d: %s", e.Index, e.ListID, e.Reason)
+}
+
+// PostingMismatchError means that the forward and the inverse posting of a document and a term don't agree.
+// A count of 0 means that the posting is missing
+type PostingMismatchError struct {
+	DocID        int32
+	TermID       int32
+	ForwardCount int32
+	InverseCount int32
+}
+
+func (e *PostingMismatchError) Error() string {
+	switch {
+	case e.InverseCount == 0:
+		return fmt.Sprintf("term %d of document %d is missing from the inverse index", e.TermID, e.DocID)
+	case e.ForwardCount == 0:
+		return fmt.Sprintf("document %d of term %d is missing from the forward index", e.DocID, e.TermID)
+	default:
+		return fmt.Sprintf(
+			"term %d is %d times in document %d in the forward index, but %d times in the inverse index",
+			e.TermID, e.ForwardCount, e.DocID, e.InverseCount,
+		)
+	}
+}
+
+// DocumentError is a problem with the information about a document
+type DocumentError struct {
+	DocID  int32
+	Reason string
+}
+
+func (e *DocumentError) Error() string {
+	return fmt.Sprintf("document %d: %s", e.DocID, e.Reason)
+}
+
+// SizeError means that two parts of the index don't agree how many items there are
+type SizeError struct {
+	What     string
+	Size     int
+	Expected int
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("%s: %d instead of %d", e.What, e.Size, e.Expected)
+}
+
+// A posting of the forward index, kept to be matched with the inverse index
+type forwardPosting struct {
+	docID int32
+	count int32
+}
+
+// Verify checks the consistency of the index and returns all the problems it finds,
+// each one a *ListError, *PostingMismatchError, *DocumentError or *SizeError
+func (t *TotalIndex) Verify() []error {
+	var errs []error
+
+	if len(t.Forward.PostingLists) != len(t.Documents) {
+		errs = append(errs, &SizeError{"forward lists", len(t.Forward.PostingLists), len(t.Documents)})
+	}
+	if len(t.Inverse.PostingLists) != int(t.Dictionary.Size) {
+		errs = append(errs, &SizeError{"inverse lists", len(t.Inverse.PostingLists), int(t.Dictionary.Size)})
+	}
+
+	errs = append(errs, t.Forward.verifyLayout("forward")...)
+	errs = append(errs, t.Inverse.verifyLayout("inverse")...)
+
+	numTerms := int32(len(t.Inverse.PostingLists))
+	numDocuments := int32(len(t.Documents))
+
+	// the forward postings by term, in order of document
+	forward := make([][]forwardPosting, numTerms)
+	deleted := make([]int, numTerms)
+	numDeleted := 0
+
+	for docID, doc := range t.Documents {
+		docID := int32(docID)
+		if doc.Deleted {
+			numDeleted++
+		}
+		if doc.ClusterID < -1 || doc.ClusterID >= len(t.Centroids) {
+			errs = append(errs, &DocumentError{docID, fmt.Sprintf("cluster %d out of %d", doc.ClusterID, len(t.Centroids))})
+		}
+		for _, class := range doc.Classes {
+			if class < 0 || class >= t.ClassNames.Size {
+				errs = append(errs, &DocumentError{docID, fmt.Sprintf("class %d out of %d", class, t.ClassNames.Size)})
+			}
+		}
+
+		if int(docID) >= len(t.Forward.PostingLists) {
+			continue
+		}
+
+		postings, err := t.Forward.verifyList("forward", docID, numTerms)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if int(doc.UniqueLength) != len(postings) {
+			errs = append(errs, &DocumentError{docID, fmt.Sprintf("unique length is %d, but it has %d terms", doc.UniqueLength, len(postings))})
+		}
+
+		for _, posting := range postings {
+			forward[posting.Index] = append(forward[posting.Index], forwardPosting{docID, posting.Count})
+			if doc.Deleted {
+				deleted[posting.Index]++
+			}
+		}
+	}
+
+	if numDeleted != t.NumDeleted {
+		errs = append(errs, &SizeError{"deleted documents", t.NumDeleted, numDeleted})
+	}
+
+	for termID := int32(0); termID < numTerms; termID++ {
+		if t.Inverse.PostingLists[termID].Deleted != deleted[termID] {
+			errs = append(errs, &ListError{"inverse", termID, fmt.Sprintf(
+				"%d postings of deleted documents are counted, but there are %d",
+				t.Inverse.PostingLists[termID].Deleted, deleted[termID],
+			)})
+		}
+
+		postings, err := t.Inverse.verifyList("inverse", termID, numDocuments)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		errs = append(errs, matchPostings(termID, forward[termID], postings)...)
+	}
+
+	for c := range t.Centroids {
+		if len(t.Centroids[c]) > int(t.Dictionary.Size) {
+			errs = append(errs, &SizeError{fmt.Sprintf("dimension of centroid %d", c), len(t.Centroids[c]), int(t.Dictionary.Size)})
+		}
+	}
+
+	return errs
+}
+
+// VerifyOrFatal logs every problem Verify finds and exits if there are any,
+// for the commands which shouldn't write an inconsistent index
+func (t *TotalIndex) VerifyOrFatal() {
+	errs := t.Verify()
+	if len(errs) == 0 {
+		return
+	}
+
+	for _, err := range errs {
+		log.Printf("Inconsistent index: %s", err)
+	}
+	log.Fatalf("Index has %d inconsistencies", len(errs))
+}
+
+// Both lists are sorted by document
+func matchPostings(termID int32, forward []forwardPosting, inverse []Posting) []error {
+	var errs []error
+
+	f, i := 0, 0
+	for f < len(forward) || i < len(inverse) {
+		switch {
+		case i == len(inverse) || (f < len(forward) && forward[f].docID < inverse[i].Index):
+			errs = append(errs, &PostingMismatchError{forward[f].docID, termID, forward[f].count, 0})
+			f++
+		case f == len(forward) || inverse[i].Index < forward[f].docID:
+			errs = append(errs, &PostingMismatchError{inverse[i].Index, termID, 0, inverse[i].Count})
+			i++
+		default:
+			if forward[f].count != inverse[i].Count {
+				errs = append(errs, &PostingMismatchError{inverse[i].Index, termID, forward[f].count, inverse[i].Count})
+			}
+			f++
+			i++
+		}
+	}
+
+	return errs
+}
+
+// Returns the postings of the list after checking that it can be read, isn't empty,
+// has the length it's supposed to and that its indices are increasing and less than max
+func (i *Index) verifyList(name string, listID int32, max int32) ([]Posting, error) {
+	var postings []Posting
+
+	if i.Encoded != nil {
+		if int(listID) >= len(i.Encoded) {
+			return nil, &ListError{name, listID, "isn't encoded"}
+		}
+		var err error
+		postings, err = DecodePostings(i.Encoded[listID])
+		if err != nil {
+			return nil, &ListError{name, listID, err.Error()}
+		}
+	} else {
+		// the links are followed with bounds checks and at most len(Postings) steps, so a cycle ends
+		for index := i.PostingLists[listID].FirstIndex; index != -1; index = i.Postings[index].NextPostingIndex {
+			if index < 0 || int(index) >= len(i.Postings) {
+				return nil, &ListError{name, listID, fmt.Sprintf("links to posting %d out of %d", index, len(i.Postings))}
+			}
+			if len(postings) == len(i.Postings) {
+				return nil, &ListError{name, listID, "has a cycle"}
+			}
+			postings = append(postings, i.Postings[index])
+		}
+	}
+
+	if len(postings) == 0 {
+		return nil, &ListError{name, listID, "is empty"}
+	}
+	if len(postings) != i.PostingLists[listID].Len {
+		return nil, &ListError{name, listID, fmt.Sprintf("has %d postings, but its length is %d", len(postings), i.PostingLists[listID].Len)}
+	}
+
+	for p := range postings {
+		if postings[p].Index < 0 || postings[p].Index >= max {
+			return nil, &ListError{name, listID, fmt.Sprintf("has index %d out of %d", postings[p].Index, max)}
+		}
+		if p > 0 && postings[p].Index <= postings[p-1].Index {
+			return nil, &ListError{name, listID, fmt.Sprintf(
+				"has out of order indices %d, %d", postings[p-1].Index, postings[p].Index,
+			)}
+		}
+	}
+
+	return postings, nil
+}
+
+// In a compact index the lists have to follow each other without gaps
+func (i *Index) verifyLayout(name string) []error {
+	if i.Encoded != nil {
+		if len(i.Encoded) != len(i.PostingLists) {
+			return []error{&SizeError{name + " encoded lists", len(i.Encoded), len(i.PostingLists)}}
+		}
+		return nil
+	}
+
+	if !i.Compact {
+		return nil
+	}
+
+	var errs []error
+	next := int32(0)
+	for listID, postingList := range i.PostingLists {
+		if postingList.Len == 0 {
+			continue
+		}
+
+		if postingList.FirstIndex != next || postingList.LastIndex != next+int32(postingList.Len)-1 {
+			errs = append(errs, &ListError{name, int32(listID), fmt.Sprintf(
+				"isn't contiguous in the compact index: first %d, last %d, length %d, expected first %d",
+				postingList.FirstIndex, postingList.LastIndex, postingList.Len, next,
+			)})
+		}
+		next += int32(postingList.Len)
+	}
+
+	if int(next) != len(i.Postings) {
+		errs = append(errs, &SizeError{"compact " + name + " postings", len(i.Postings), int(next)})
+	}
+
+	return errs
+}
@@ -0,0 +1,86 @@
+package indices
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeVerifyIndex() *TotalIndex {
+	ti := NewTotalIndex()
+	ti.Add(makeDocument("foo", "bar"))
+	ti.Add(makeDocument("bar", "qux"))
+	ti.Add(makeDocument("foo", "qux", "qux"))
+	ti.Finalise()
+	return ti
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeVerifyIndex()
+	assert.Empty(ti.Verify())
+
+	foo := ti.Dictionary.Find([]byte("foo"))
+	qux := ti.Dictionary.Find([]byte("qux"))
+
+	// every problem is reported, not only the first one
+	ti.Inverse.List(qux)[1].Count = 5
+	ti.Documents[0].UniqueLength = 3
+	ti.Documents[1].ClusterID = 2
+	errs := ti.Verify()
+	assert.Len(errs, 3)
+
+	assert.Equal(&DocumentError{DocID: 0, Reason: "unique length is 3, but it has 2 terms"}, errs[0])
+	assert.IsType(&DocumentError{}, errs[1])
+	assert.Equal(int32(1), errs[1].(*DocumentError).DocID)
+	assert.Equal(&PostingMismatchError{DocID: 2, TermID: qux, ForwardCount: 2, InverseCount: 5}, errs[2])
+
+	ti = makeVerifyIndex()
+	list := ti.Inverse.List(foo)
+	list[0], list[1] = list[1], list[0]
+	errs = ti.Verify()
+	assert.Len(errs, 1)
+	assert.IsType(&ListError{}, errs[0])
+	assert.Equal("inverse", errs[0].(*ListError).Index)
+	assert.Equal(foo, errs[0].(*ListError).ListID)
+
+	// a posting missing from the inverse index
+	ti = makeVerifyIndex()
+	ti.Inverse.PostingLists[foo].Len = 1
+	ti.Inverse.Postings[ti.Inverse.PostingLists[foo].FirstIndex].NextPostingIndex = -1
+	ti.Inverse.Compact = false
+	errs = ti.Verify()
+	assert.Len(errs, 1)
+	assert.Equal(&PostingMismatchError{DocID: 2, TermID: foo, ForwardCount: 1, InverseCount: 0}, errs[0])
+
+	// a term without a list and a cycle
+	ti = makeVerifyIndex()
+	ti.Dictionary.Get([]byte("new"))
+	ti.Inverse.Compact = false
+	ti.Inverse.Postings[ti.Inverse.PostingLists[qux].LastIndex].NextPostingIndex = ti.Inverse.PostingLists[qux].FirstIndex
+	errs = ti.Verify()
+	assert.Len(errs, 2)
+	assert.Equal(&SizeError{What: "inverse lists", Size: 3, Expected: 4}, errs[0])
+	assert.Equal(&ListError{Index: "inverse", ListID: qux, Reason: "has a cycle"}, errs[1])
+
+	ti = makeVerifyIndex()
+	ti.NumDeleted = 1
+	ti.Forward.Compress()
+	ti.Forward.Encoded[1] = ti.Forward.Encoded[1][:2]
+	errs = ti.Verify()
+	assert.Len(errs, 4)
+	assert.IsType(&ListError{}, errs[0])
+	assert.Equal(&SizeError{What: "deleted documents", Size: 1, Expected: 0}, errs[1])
+	// the postings of the corrupt list are missing from the inverse index
+	assert.IsType(&PostingMismatchError{}, errs[2])
+	assert.IsType(&PostingMismatchError{}, errs[3])
+
+	// a garbage length is reported, not allocated
+	ti = makeVerifyIndex()
+	ti.Inverse.Compress()
+	ti.Inverse.Encoded[foo] = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}
+	errs = ti.Verify()
+	assert.NotEmpty(errs)
+	assert.Equal(&ListError{Index: "inverse", ListID: foo, Reason: "length 9223372036854775807 of posting list is more than its 9 bytes can hold"}, errs[0])
+}
@@ -25,6 +25,9 @@ func tableise(index *indices.TotalIndex, k int) [][]int {
 	}
 
 	for _, doc := range index.Documents {
+		if doc.Deleted {
+			continue
+		}
 		for _, class := range doc.Classes {
 			t[class][doc.ClusterID] += 1
 		}
@@ -42,6 +45,9 @@ func Purity(index *indices.TotalIndex, k int) (float64, []map[int32]int) {
 	}
 
 	for _, doc := range index.Documents {
+		if doc.Deleted {
+			continue
+		}
 		if len(doc.Classes) != 0 {
 			docWithClasses += 1
 		}
@@ -75,6 +81,8 @@ func getMaxDict(d map[int32]int) int {
 }
 
 func KMeans(index *indices.TotalIndex, k int) []float64 {
+	index.Finalise()
+
 	// Initialise this set in order to produce k random indices, because there isn't a way to get k
 	// random numbers at once
 
@@ -84,6 +92,9 @@ func KMeans(index *indices.TotalIndex, k int) []float64 {
 	rand.Seed(time.Now().UTC().UnixNano())
 	for len(centroidIndices) < k {
 		ind := rand.Int31n(int32(len(index.Forward.PostingLists)))
+		if index.Documents[ind].Deleted {
+			continue
+		}
 		if _, ok := centroidIndices[ind]; !ok {
 			centroidIndices[ind] = struct{}{}
 		}
@@ -131,6 +142,9 @@ func KMeans(index *indices.TotalIndex, k int) []float64 {
 
 		utils.Parallel(func() {
 			for docID := range docIdChannel {
+				if index.Documents[docID].Deleted {
+					continue
+				}
 				centroidIndex := closestCentroid(index, docID)
 				index.Documents[docID].ClusterID = centroidIndex
 			}
@@ -149,7 +163,7 @@ func tf(count, len int32) float64 {
 }
 
 func idf(index *indices.TotalIndex, termID int32) float64 {
-	return math.Log(float64(len(index.Documents)) / float64(1+index.Inverse.PostingLists[termID].Len))
+	return index.IDF(termID)
 }
 
 // Empty old centroids
@@ -164,6 +178,9 @@ func NewCentroids(index *indices.TotalIndex, k int) {
 
 	numberOfDocuments := make([]int32, k, k)
 	for docID, doc := range index.Documents {
+		if doc.Deleted {
+			continue
+		}
 		index.LoopOverDocumentPostings(int32(docID), func(posting *indices.Posting) {
 			// (*index).Centroids[doc.ClusterID][posting.Index] += tf(posting.Count, doc.Length) * idf(index, posting.Index)
 			(*index).Centroids[doc.ClusterID][posting.Index] += tf(posting.Count, doc.Length)
@@ -187,6 +204,9 @@ func rss(index *indices.TotalIndex) float64 {
 	var sum float64
 
 	for docID, doc := range index.Documents {
+		if doc.Deleted {
+			continue
+		}
 		if doc.ClusterID == -1 {
 			return -1
 		}
@@ -199,7 +219,9 @@ func rss(index *indices.TotalIndex) float64 {
 func PrintClusters(index *indices.TotalIndex, k int) {
 	clusterNums := make([]int, k, k)
 	for _, doc := range index.Documents {
-		clusterNums[doc.ClusterID] += 1
+		if !doc.Deleted {
+			clusterNums[doc.ClusterID] += 1
+		}
 	}
 
 	for i := 0; i < len(clusterNums); i++ {
@@ -224,30 +246,45 @@ func closestCentroid(index *indices.TotalIndex, documentId int32) int {
 	return ind
 }
 
+// AssignClusters puts the documents from docID on in the cluster with the closest centroid,
+// without moving the centroids. It's used for documents appended to a clustered index
+func AssignClusters(index *indices.TotalIndex, docID int32) {
+	if len(index.Centroids) == 0 {
+		return
+	}
+
+	index.Finalise()
+	for ; docID < int32(len(index.Documents)); docID++ {
+		if !index.Documents[docID].Deleted {
+			index.Documents[docID].ClusterID = closestCentroid(index, docID)
+		}
+	}
+}
+
 // Finds the squared distance between the centroid (witch is an array with exact length of the total number of terms)
-// and a document (which is a much sparser array)
+// and a document (which is a much sparser array). The index has to be finalised.
+// Terms added after the centroids were computed are 0 in all of them
 func distance(index *indices.TotalIndex, centroidIndex int, documentId int32) float64 {
 	sum := float64(0)
 	doclen := index.Documents[documentId].Length
 	centroid := (*index).Centroids[centroidIndex]
 
-	posting := &index.Forward.Postings[index.Forward.PostingLists[documentId].FirstIndex]
-	ind := posting.Index
+	postings := index.DocumentPostings(documentId)
+	p := 0
 
 	for i := 0; i < len(centroid); i++ {
-		if int32(i) == ind {
-			// sum += sqr(centroid[i] - tf(posting.Count, doclen)*idf(index, posting.Index))
-			sum += sqr(centroid[i] - tf(posting.Count, doclen))
-			if posting.NextPostingIndex != int32(-1) {
-				posting = &index.Forward.Postings[posting.NextPostingIndex]
-				ind = posting.Index
-			} else {
-				ind = -1
-			}
+		if p < len(postings) && int32(i) == postings[p].Index {
+			// sum += sqr(centroid[i] - tf(postings[p].Count, doclen)*idf(index, postings[p].Index))
+			sum += sqr(centroid[i] - tf(postings[p].Count, doclen))
+			p++
 		} else {
 			sum += sqr(centroid[i])
 		}
 	}
+
+	for ; p < len(postings); p++ {
+		sum += sqr(tf(postings[p].Count, doclen))
+	}
 	return sum
 }
 
@@ -263,7 +300,8 @@ func distanceToInfo(index *indices.TotalIndex, centroidIndex int, info *indices.
 	termIndices := make([]IndexedTerm, 0, info.TermsAndCounts.Size())
 	var ind int32
 	info.TermsAndCounts.Walk(func(word []byte, value int32) {
-		ind = index.Dictionary.Get(word)
+		// Find, since Get would add the word and thaw the frozen dictionary
+		ind = index.Dictionary.Find(word)
 		if ind != -1 {
 			termIndices = append(termIndices, IndexedTerm{index: ind, count: value})
 		}
@@ -273,7 +311,7 @@ func distanceToInfo(index *indices.TotalIndex, centroidIndex int, info *indices.
 
 	j := 0
 	for i := 0; i < len(centroid); i++ {
-		if i == int(termIndices[j].index) {
+		if j < len(termIndices) && i == int(termIndices[j].index) {
 			sum += sqr(centroid[i] - tf(termIndices[j].count, info.Length)*idf(index, int32(i)))
 			if j < len(termIndices)-1 {
 				j++
@@ -1,6 +1,7 @@
 package kmeans
 
 import (
+	"math"
 	"testing"
 
 	"github.com/bitterfly/search/indices"
@@ -47,43 +48,73 @@ func TestNormalise(t *testing.T) {
 	assert.InDelta(float32(0.5), ti.Forward.Postings[ti.Forward.PostingLists[1].LastIndex].NormalisedCount, float64(0.0001))
 }
 
-func TestDistance(t *testing.T) {
+func TestIDF(t *testing.T) {
 	assert := assert.New(t)
 
 	ti := makeIndex()
+	foo := ti.Dictionary.Find([]byte("foo"))
+	bar := ti.Dictionary.Find([]byte("bar"))
+
+	// the first posting of a term is counted, bar is in both documents
+	assert.Equal(1, ti.Inverse.PostingLists[foo].Len)
+	assert.Equal(2, ti.Inverse.PostingLists[bar].Len)
+	assert.InDelta(math.Log(2.0/2.0), idf(ti, foo), 0.0001)
+	assert.InDelta(math.Log(2.0/3.0), idf(ti, bar), 0.0001)
+
+	// deleted documents are neither counted nor contain the term
+	assert.Nil(ti.Delete(1))
+	assert.InDelta(math.Log(1.0/2.0), idf(ti, bar), 0.0001)
+}
 
-	ti.Normalise()
-
-	dist := ((0-2)*(0-2) + (0.5-1)*(0.5-1) + (1-0)*(1-0))
-
-	assert.InDelta(dist, distance(0, []float32{0, 0.5, 1}, ti), float64(0.0001))
+// Returns a centroid with the given coordinates for the terms
+func makeCentroid(ti *indices.TotalIndex, coordinates map[string]float64) []float64 {
+	centroid := make([]float64, ti.Dictionary.Size)
+	for term, x := range coordinates {
+		centroid[ti.Dictionary.Find([]byte(term))] = x
+	}
+	return centroid
 }
 
-func TestNewCentroid(t *testing.T) {
+func TestDistance(t *testing.T) {
 	assert := assert.New(t)
 
 	ti := makeIndex()
+	ti.Finalise()
+	ti.Centroids = [][]float64{makeCentroid(ti, map[string]float64{"foo": 1, "bar": 0.5, "qux": 1})}
 
-	ti.Normalise()
+	// doc0 has tf 2/3 for foo and 1/3 for bar
+	dist := sqr(1-2.0/3) + sqr(0.5-1.0/3) + sqr(1)
 
-	assert.InDeltaSlice([]float32{1, 1, 0.5}, newCentroid([]int32{0, 1}, ti), 0.001)
+	assert.InDelta(dist, distance(ti, 0, 0), float64(0.0001))
 }
 
-func TestClosestCentroid(t *testing.T) {
+func TestNewCentroids(t *testing.T) {
 	assert := assert.New(t)
 
 	ti := makeIndex()
+	ti.Finalise()
+	ti.Centroids = [][]float64{make([]float64, ti.Dictionary.Size)}
+	ti.Documents[0].ClusterID = 0
+	ti.Documents[1].ClusterID = 0
 
-	ti.Normalise()
+	NewCentroids(ti, 1)
 
-	// d0 = {1, 0.5}
-	// d1 = {0.5, 0.5}
+	// the mean of the tf of the documents
+	expected := makeCentroid(ti, map[string]float64{"foo": 1.0 / 3, "bar": (1.0/3 + 0.5) / 2, "qux": 0.25})
+	assert.InDeltaSlice(expected, ti.Centroids[0], 0.001)
+}
 
-	centroids := make([][]float32, 2, 2)
-	centroids[0] = []float32{1, 0, 0}
-	centroids[1] = []float32{0, 1, 0}
+func TestClosestCentroid(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeIndex()
+	ti.Finalise()
 
-	assert.Equal(0, closestCentroid(0, &centroids, ti))
-	// assert.Equal(int32(1), closestCentroid(1, &centroids, ti))
+	ti.Centroids = [][]float64{
+		makeCentroid(ti, map[string]float64{"foo": 1}),
+		makeCentroid(ti, map[string]float64{"qux": 1}),
+	}
 
+	assert.Equal(0, closestCentroid(ti, 0))
+	assert.Equal(1, closestCentroid(ti, 1))
 }
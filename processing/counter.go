@@ -26,14 +26,11 @@ func Count(doc *documents.Document, tokeniser Tokeniser) *indices.InfoAndTerms {
 	idoc := indices.NewInfoAndTerms()
 	idoc.Name = doc.Title
 	idoc.Classes = doc.Classes
+	idoc.Date = doc.Date
+	idoc.Body = doc.Body
 
 	tokeniser.GetTerms(doc.Body, func(term string) {
-		idoc.TermsAndCounts.PutLambda(
-			[]byte(term),
-			func(x int32) int32 { return x + 1 },
-			1,
-		)
-		idoc.Length += 1
+		idoc.AddTerm(term)
 	})
 
 	return idoc
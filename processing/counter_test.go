@@ -0,0 +1,69 @@
+package processing
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/bitterfly/search/documents"
+	"github.com/bitterfly/search/indices"
+)
+
+// Counts the documents in the Reuters XML files in $REUTERS_DIR, the benchmarks are skipped without it
+func reutersDocuments(b *testing.B) []*indices.InfoAndTerms {
+	dir := os.Getenv("REUTERS_DIR")
+	if dir == "" {
+		b.Skip("REUTERS_DIR isn't set")
+	}
+
+	tokeniser, err := NewEnglishTokeniserFromFile(filepath.Join(dir, "stopwords"))
+	if err != nil {
+		b.Fatalf("unable to get stopwords: %s", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.xml"))
+	if err != nil {
+		b.Fatalf("unable to get files in %s: %s", dir, err)
+	}
+
+	var infosAndTerms []*indices.InfoAndTerms
+	parser := documents.NewReutersParser()
+	for _, file := range files {
+		docs, err := parser.ParseFile(file)
+		if err != nil {
+			b.Fatalf("unable to parse %s: %s", file, err)
+		}
+		for _, doc := range docs {
+			infosAndTerms = append(infosAndTerms, Count(doc, tokeniser))
+		}
+	}
+
+	return infosAndTerms
+}
+
+func benchmarkIndexReuters(b *testing.B, workers int) {
+	infosAndTerms := reutersDocuments(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		idocs := make(chan *indices.InfoAndTerms, 2000)
+		go func() {
+			for _, it := range infosAndTerms {
+				idocs <- it
+			}
+			close(idocs)
+		}()
+
+		ti := indices.NewTotalIndex()
+		if workers == 1 {
+			ti.AddMany(idocs)
+			ti.Finalise()
+		} else if err := ti.AddManyParallel(idocs, workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkIndexReuters(b *testing.B)         { benchmarkIndexReuters(b, 1) }
+func BenchmarkIndexReutersParallel(b *testing.B) { benchmarkIndexReuters(b, runtime.NumCPU()) }
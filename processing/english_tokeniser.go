@@ -2,8 +2,11 @@ package processing
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"unicode"
 
@@ -13,7 +16,8 @@ import (
 )
 
 type EnglishTokeniser struct {
-	stopWords trie.Trie
+	stopWords     trie.Trie
+	stopWordsHash string
 }
 
 func NewEnglishTokeniserFromFile(stopWordFile string) (*EnglishTokeniser, error) {
@@ -40,15 +44,34 @@ func NewEnglishTokeniser(stopWordList io.Reader) (*EnglishTokeniser, error) {
 		stopWords: *trie.New(),
 	}
 
+	var words []string
 	scanner := bufio.NewScanner(stopWordList)
 	for scanner.Scan() {
 		tok.stopWords.Put([]byte(scanner.Text()), 1)
 		tok.stopWords.Put([]byte(tok.stem(scanner.Text())), 1)
+		words = append(words, scanner.Text())
 	}
 
+	// the order of the list doesn't matter
+	sort.Strings(words)
+	hash := sha256.New()
+	for _, word := range words {
+		fmt.Fprintln(hash, word)
+	}
+	tok.stopWordsHash = fmt.Sprintf("%x", hash.Sum(nil))
+
 	return tok, scanner.Err()
 }
 
+func (e *EnglishTokeniser) Name() string {
+	return "english-treebank-snowball"
+}
+
+// StopWordsHash is the sha256 of the sorted stop word list
+func (e *EnglishTokeniser) StopWordsHash() string {
+	return e.stopWordsHash
+}
+
 func (e *EnglishTokeniser) notPunctuation(word string) bool {
 	if len(word) == 0 {
 		return false
@@ -0,0 +1,68 @@
+package processing
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/urfave/cli"
+)
+
+// ErrNoMetadata is returned for indices serialised before they had metadata
+// and for imported ones, which don't record their tokeniser, so they can't be checked
+var ErrNoMetadata = errors.New("index has no metadata")
+
+type describedTokeniser interface {
+	Name() string
+	StopWordsHash() string
+}
+
+// Describe returns the name of the tokeniser and the hash of its stop words,
+// if it doesn't provide them the name is its type
+func Describe(tokeniser Tokeniser) (string, string) {
+	if described, ok := tokeniser.(describedTokeniser); ok {
+		return described.Name(), described.StopWordsHash()
+	}
+	return fmt.Sprintf("%T", tokeniser), ""
+}
+
+// SetMetadata records the tokeniser in the metadata of the index
+func SetMetadata(index *indices.TotalIndex, tokeniser Tokeniser) {
+	index.Metadata.Tokeniser, index.Metadata.StopWordsHash = Describe(tokeniser)
+}
+
+// CheckCompatible returns an error if the index was built with another tokeniser or stop word list,
+// so queries would be tokenised differently from the documents
+func CheckCompatible(metadata indices.Metadata, tokeniser Tokeniser) error {
+	if metadata.FormatVersion == 0 || metadata.Tokeniser == "" {
+		return ErrNoMetadata
+	}
+
+	name, hash := Describe(tokeniser)
+	if metadata.Tokeniser != name {
+		return fmt.Errorf("index was built with tokeniser %s, not %s", metadata.Tokeniser, name)
+	}
+	if metadata.StopWordsHash != hash {
+		return fmt.Errorf("index was built with a different stop word list")
+	}
+
+	return nil
+}
+
+// IgnoreMetadataFlag is the flag of the commands which pass it to CheckOrFatal
+var IgnoreMetadataFlag = cli.BoolFlag{
+	Name:  "ignore-metadata",
+	Usage: "Use the index even if it was built with a different tokeniser or stop words",
+}
+
+// CheckOrFatal exits if the index isn't compatible with the tokeniser, unless ignore is set.
+// An index without metadata is only warned about
+func CheckOrFatal(metadata indices.Metadata, tokeniser Tokeniser, ignore bool) {
+	err := CheckCompatible(metadata, tokeniser)
+	if err == ErrNoMetadata {
+		log.Printf("warning: %s, text may be tokenised differently from the indexed documents", err)
+	} else if err != nil && !ignore {
+		log.Fatalf("incompatible index: %s", err)
+	}
+}
@@ -0,0 +1,50 @@
+package processing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+)
+
+func TestCheckCompatible(t *testing.T) {
+	tokeniser, err := NewEnglishTokeniser(strings.NewReader("the\nof\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reordered, err := NewEnglishTokeniser(strings.NewReader("of\nthe\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewEnglishTokeniser(strings.NewReader("the\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	index := indices.NewTotalIndex()
+	if CheckCompatible(index.Metadata, tokeniser) != ErrNoMetadata {
+		t.Errorf("An index without metadata should not be checked")
+	}
+
+	index.Metadata.FormatVersion = indices.FormatVersion
+	if CheckCompatible(index.Metadata, tokeniser) != ErrNoMetadata {
+		t.Errorf("An index without a tokeniser should not be checked")
+	}
+
+	SetMetadata(index, tokeniser)
+
+	if err := CheckCompatible(index.Metadata, reordered); err != nil {
+		t.Errorf("The order of the stop words should not matter, but got %s", err)
+	}
+
+	if CheckCompatible(index.Metadata, other) == nil {
+		t.Errorf("Different stop words should be incompatible")
+	}
+
+	index.Metadata.Tokeniser = "other"
+	if CheckCompatible(index.Metadata, tokeniser) == nil {
+		t.Errorf("A different tokeniser should be incompatible")
+	}
+}
@@ -0,0 +1,38 @@
+// Package processingtest has helpers for the tests of packages which tokenise text
+package processingtest
+
+import "strings"
+
+// Tokeniser lower cases and splits on spaces, so the tests don't depend on the stemmer
+type Tokeniser struct {
+	stopWords map[string]bool
+}
+
+func NewTokeniser(stopWords ...string) *Tokeniser {
+	tt := &Tokeniser{stopWords: make(map[string]bool)}
+	for _, word := range stopWords {
+		tt.stopWords[word] = true
+	}
+	return tt
+}
+
+func (tt *Tokeniser) Tokenise(text string) []string {
+	return strings.Fields(text)
+}
+
+func (tt *Tokeniser) Normalise(token string) string {
+	return strings.ToLower(token)
+}
+
+func (tt *Tokeniser) IsStopWord(word string) bool {
+	return tt.stopWords[word]
+}
+
+func (tt *Tokeniser) GetTerms(text string, operation func(string)) {
+	for _, token := range tt.Tokenise(text) {
+		term := tt.Normalise(token)
+		if !tt.IsStopWord(term) {
+			operation(term)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package query
+
+import (
+	"sort"
+	"strings"
+)
+
+const DefaultFuzzyDistance = 2
+
+type Suggestion struct {
+	Term      string
+	Distance  int
+	Frequency int
+}
+
+// The dictionary only contains normalised terms, so the word is normalised too
+// unless that turns it into anything but a single term
+func (e *Engine) fuzzyTerm(word string) string {
+	terms := e.normalise(word)
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return strings.ToLower(word)
+}
+
+// Suggest returns the terms in the dictionary at most maxDistance edits away from the word,
+// the closest first and among equally close ones those in more documents first.
+// At most MaxExpansions suggestions are returned
+func (e *Engine) Suggest(word string, maxDistance int) []Suggestion {
+	var suggestions []Suggestion
+	e.index.WalkFuzzy([]byte(e.fuzzyTerm(word)), maxDistance, func(term []byte, termID int32, distance int) {
+		if int(termID) < e.index.NumTerms() && e.index.DocumentFrequency(termID) > 0 {
+			suggestions = append(suggestions, Suggestion{
+				Term:      string(term),
+				Distance:  distance,
+				Frequency: e.index.DocumentFrequency(termID),
+			})
+		}
+	})
+
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Distance != suggestions[j].Distance {
+			return suggestions[i].Distance < suggestions[j].Distance
+		}
+		if suggestions[i].Frequency != suggestions[j].Frequency {
+			return suggestions[i].Frequency > suggestions[j].Frequency
+		}
+		return suggestions[i].Term < suggestions[j].Term
+	})
+
+	if len(suggestions) > e.MaxExpansions {
+		suggestions = suggestions[:e.MaxExpansions]
+	}
+
+	return suggestions
+}
+
+// Returns a word which normalises to the term by putting the ending, which normalising cut off
+// the misspelt word, back on the term, e.g. companies for compani and the word compnies.
+// If there's no such word the term itself is returned
+func (e *Engine) surfaceForm(word string, term string) string {
+	lower := strings.ToLower(word)
+	stem := e.fuzzyTerm(word)
+	if !strings.HasPrefix(lower, stem) {
+		return term
+	}
+
+	candidate := term + lower[len(stem):]
+	if terms := e.normalise(candidate); len(terms) == 1 && terms[0] == term {
+		return candidate
+	}
+	return term
+}
+
+// DidYouMean replaces every plain term of the query which isn't in the index with
+// a word normalising to its best suggestion, see surfaceForm. Terms which are in the index
+// are left as they were typed. It returns false if nothing could be corrected
+func (e *Engine) DidYouMean(text string, maxDistance int) (string, bool) {
+	tokens, err := lex(text)
+	if err != nil {
+		return "", false
+	}
+
+	var corrected strings.Builder
+	last := 0
+	changed := false
+
+	for _, t := range tokens {
+		if t.kind != tokenWord || strings.ContainsAny(t.text, "*?~") {
+			continue
+		}
+
+		terms := e.normalise(t.text)
+		if len(terms) != 1 || e.index.Find([]byte(terms[0])) != -1 {
+			continue
+		}
+
+		suggestions := e.Suggest(t.text, maxDistance)
+		if len(suggestions) == 0 || suggestions[0].Distance == 0 {
+			continue
+		}
+
+		corrected.WriteString(text[last:t.start])
+		corrected.WriteString(e.surfaceForm(t.text, suggestions[0].Term))
+		last = t.start + len(t.text)
+		changed = true
+	}
+
+	if !changed {
+		return "", false
+	}
+
+	corrected.WriteString(text[last:])
+	return corrected.String(), true
+}
+
+func (f *Fuzzy) evaluate(e *Engine) ([]int32, bool) {
+	var docs []int32
+	for _, suggestion := range e.Suggest(f.Word, f.Distance) {
+		docs = union(docs, e.termDocuments(suggestion.Term))
+	}
+	return docs, true
+}
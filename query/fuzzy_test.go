@@ -0,0 +1,87 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggest(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine(makeIndex(
+		"oil prices",
+		"oil exports",
+		"soil",
+		"oils",
+		"interest rates",
+	), testTokeniser)
+
+	assert.Equal([]Suggestion{
+		{Term: "oil", Distance: 1, Frequency: 2},
+		{Term: "oils", Distance: 1, Frequency: 1},
+		{Term: "soil", Distance: 2, Frequency: 1},
+	}, e.Suggest("OIs", 2))
+
+	assert.Equal([]Suggestion{{Term: "oil", Distance: 0, Frequency: 2}}, e.Suggest("oil", 0))
+	assert.Empty(e.Suggest("gold", 1))
+}
+
+func TestDidYouMean(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine(makeIndex(
+		"oil prices",
+		"oil exports",
+		"interest rates",
+	), testTokeniser)
+
+	corrected, ok := e.DidYouMean("oli AND (intrest OR exports)", 2)
+	assert.True(ok)
+	assert.Equal("oil AND (interest OR exports)", corrected)
+
+	_, ok = e.DidYouMean("oil AND exports", 2)
+	assert.False(ok)
+
+	_, ok = e.DidYouMean("zzzzzz", 2)
+	assert.False(ok)
+}
+
+func TestDidYouMean_Stemmed(t *testing.T) {
+	assert := assert.New(t)
+
+	tokeniser, err := processing.NewEnglishTokeniser(strings.NewReader("the\n"))
+	assert.Nil(err)
+
+	ti := indices.NewTotalIndex()
+	for _, text := range []string{"the companies", "oil exports"} {
+		doc := indices.NewInfoAndTerms()
+		tokeniser.GetTerms(text, doc.AddTerm)
+		ti.Add(doc)
+	}
+
+	// the words are corrected, not their stems, and the ones which match stay as they were typed
+	e := NewEngine(ti, tokeniser)
+	corrected, ok := e.DidYouMean("Compnies AND exprots OR Exports", 2)
+	assert.True(ok)
+	assert.Equal("companies AND exports OR Exports", corrected)
+}
+
+func TestSearch_Fuzzy(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine(makeIndex(
+		"oil prices",
+		"soil",
+		"oils",
+		"boil water",
+	), testTokeniser)
+
+	assert.Equal([]int32{0, 1, 2, 3}, search(t, e, "oil~1"))
+	assert.Equal([]int32{0}, search(t, e, "oil~0"))
+	assert.Equal([]int32{0, 2}, search(t, e, "oli~"))
+	assert.Equal([]int32{3}, search(t, e, "oil~ AND watr~1"))
+}
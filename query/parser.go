@@ -0,0 +1,293 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenNear
+	tokenPhrase
+	tokenOpen
+	tokenClose
+	tokenEnd
+)
+
+type token struct {
+	kind tokenKind
+	text string
+
+	// byte offset of the token in the query
+	start int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEnd {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// Splits the query into words, quoted phrases, parentheses and
+// the (upper case) operators AND, OR, NOT and NEAR/<distance>
+func lex(text string) ([]token, error) {
+	var tokens []token
+	var word strings.Builder
+	var phrase strings.Builder
+	inPhrase := false
+	wordStart := 0
+
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+
+		w := word.String()
+		switch w {
+		case "AND":
+			tokens = append(tokens, token{kind: tokenAnd, text: w, start: wordStart})
+		case "OR":
+			tokens = append(tokens, token{kind: tokenOr, text: w, start: wordStart})
+		case "NOT":
+			tokens = append(tokens, token{kind: tokenNot, text: w, start: wordStart})
+		default:
+			if strings.HasPrefix(w, "NEAR/") {
+				tokens = append(tokens, token{kind: tokenNear, text: w, start: wordStart})
+				break
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: w, start: wordStart})
+		}
+		word.Reset()
+	}
+
+	for i, symbol := range text {
+		if inPhrase {
+			if symbol == '"' {
+				tokens = append(tokens, token{kind: tokenPhrase, text: phrase.String()})
+				phrase.Reset()
+				inPhrase = false
+			} else {
+				phrase.WriteRune(symbol)
+			}
+			continue
+		}
+
+		switch {
+		case symbol == '"':
+			flush()
+			inPhrase = true
+		case symbol == '(':
+			flush()
+			tokens = append(tokens, token{kind: tokenOpen, text: "("})
+		case symbol == ')':
+			flush()
+			tokens = append(tokens, token{kind: tokenClose, text: ")"})
+		case unicode.IsSpace(symbol):
+			flush()
+		default:
+			if word.Len() == 0 {
+				wordStart = i
+			}
+			word.WriteRune(symbol)
+		}
+	}
+	flush()
+
+	if inPhrase {
+		return nil, fmt.Errorf("unterminated phrase")
+	}
+
+	return append(tokens, token{kind: tokenEnd}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// Parse builds the syntax tree of a boolean query.
+// NEAR binds tighter than NOT which binds tighter than AND which binds tighter than OR,
+// two terms without an operator between them are joined with AND.
+func Parse(text string) (Node, error) {
+	tokens, err := lex(text)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+
+	if p.peek().kind == tokenEnd {
+		return nil, fmt.Errorf("empty query")
+	}
+
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokenEnd {
+		return nil, fmt.Errorf("unexpected %s", p.peek())
+	}
+
+	return node, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Node, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for p.peek().kind == tokenOr {
+		p.next()
+		child, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Or{Children: children}, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	first, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	children := []Node{first}
+	for {
+		switch p.peek().kind {
+		case tokenAnd:
+			p.next()
+		case tokenWord, tokenPhrase, tokenNot, tokenOpen:
+			// implicit AND
+		default:
+			if len(children) == 1 {
+				return first, nil
+			}
+			return &And{Children: children}, nil
+		}
+
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.peek().kind == tokenNot {
+		p.next()
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Child: child}, nil
+	}
+
+	return p.parseNear()
+}
+
+// Only two single terms can be joined with NEAR
+func (p *parser) parseNear() (Node, error) {
+	first, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != tokenNear {
+		return first, nil
+	}
+
+	operator := p.next()
+	distance, err := strconv.Atoi(strings.TrimPrefix(operator.text, "NEAR/"))
+	if err != nil || distance < 1 {
+		return nil, fmt.Errorf("invalid distance in %s", operator)
+	}
+
+	second, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	left, leftIsTerm := first.(*Term)
+	right, rightIsTerm := second.(*Term)
+	if !leftIsTerm || !rightIsTerm {
+		return nil, fmt.Errorf("%s can only join two terms", operator)
+	}
+
+	if p.peek().kind == tokenNear {
+		return nil, fmt.Errorf("%s can only join two terms", p.peek())
+	}
+
+	return &Near{Left: left, Right: right, Distance: int32(distance)}, nil
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenWord:
+		if strings.ContainsAny(t.text, "*?") {
+			return &Wildcard{Pattern: t.text}, nil
+		}
+		if tilde := strings.LastIndexByte(t.text, '~'); tilde > 0 {
+			return parseFuzzy(t, tilde)
+		}
+		return &Term{Word: t.text}, nil
+	case tokenPhrase:
+		return &Phrase{Text: t.text}, nil
+	case tokenOpen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenClose {
+			return nil, fmt.Errorf("expected \")\" but got %s", closing)
+		}
+		return node, nil
+	default:
+		return nil, fmt.Errorf("expected a term but got %s", t)
+	}
+}
+
+// Fuzzy terms look like word~ or word~<distance>
+func parseFuzzy(t token, tilde int) (Node, error) {
+	distance := DefaultFuzzyDistance
+	if tilde < len(t.text)-1 {
+		var err error
+		distance, err = strconv.Atoi(t.text[tilde+1:])
+		if err != nil || distance < 0 {
+			return nil, fmt.Errorf("invalid distance in %s", t)
+		}
+	}
+
+	return &Fuzzy{Word: t.text[:tilde], Distance: distance}, nil
+}
@@ -0,0 +1,44 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	queries := map[string]string{
+		"oil":                          "oil",
+		"oil AND gas":                  "(oil AND gas)",
+		"oil gas":                      "(oil AND gas)",
+		"oil OR gas AND coal":          "(oil OR (gas AND coal))",
+		"(oil OR gas) AND coal":        "((oil OR gas) AND coal)",
+		"oil AND NOT gas":              "(oil AND NOT gas)",
+		"NOT NOT oil":                  "NOT NOT oil",
+		"oil or gas":                   "(oil AND or AND gas)",
+		"((oil))":                      "oil",
+		"oil OR (gas NOT coal) OR tea": "(oil OR (gas AND NOT coal) OR tea)",
+	}
+
+	for text, expected := range queries {
+		node, err := Parse(text)
+		if assert.Nil(err, text) {
+			assert.Equal(expected, node.String(), text)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, text := range []string{
+		"", "   ", "oil AND", "(oil", "oil)", "OR oil", "NOT", "()",
+		`"crude oil`, "oil NEAR/3", "oil NEAR/x gas", "oil NEAR/0 gas",
+		`oil NEAR/2 "crude oil"`, "oil NEAR/2 gas NEAR/2 coal", "oil* NEAR/2 gas", "oil~x",
+	} {
+		_, err := Parse(text)
+		assert.NotNil(err, text)
+	}
+}
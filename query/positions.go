@@ -0,0 +1,53 @@
+package query
+
+// Positions of a term in a document are sorted in increasing order
+
+// Returns whether there is a position p of the first term such that
+// the i-th term is at position p + i
+func isPhrase(positions [][]int32) bool {
+	cursors := make([]int, len(positions))
+
+	for _, start := range positions[0] {
+		found := true
+		for i := 1; i < len(positions); i++ {
+			for cursors[i] < len(positions[i]) && positions[i][cursors[i]] < start+int32(i) {
+				cursors[i]++
+			}
+			if cursors[i] >= len(positions[i]) {
+				return false
+			}
+			if positions[i][cursors[i]] != start+int32(i) {
+				found = false
+				break
+			}
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns whether some position in a and some position in b are at most distance apart
+func areNear(a, b []int32, distance int32) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		difference := a[i] - b[j]
+		if difference < 0 {
+			difference = -difference
+		}
+		if difference <= distance {
+			return true
+		}
+
+		if a[i] < b[j] {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package query
+
+// All the functions here work on sorted lists of document ids without duplicates
+
+func intersect(a, b []int32) []int32 {
+	result := make([]int32, 0, min(len(a), len(b)))
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+
+	return result
+}
+
+func union(a, b []int32) []int32 {
+	result := make([]int32, 0, len(a)+len(b))
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			result = append(result, a[i])
+			i++
+		case a[i] > b[j]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+
+	result = append(result, a[i:]...)
+	return append(result, b[j:]...)
+}
+
+// Returns the documents in a which are not in b
+func difference(a, b []int32) []int32 {
+	result := make([]int32, 0, len(a))
+
+	j := 0
+	for i := range a {
+		for j < len(b) && b[j] < a[i] {
+			j++
+		}
+		if j < len(b) && b[j] == a[i] {
+			continue
+		}
+		result = append(result, a[i])
+	}
+
+	return result
+}
+
+func min(i, j int) int {
+	if i < j {
+		return i
+	}
+	return j
+}
@@ -0,0 +1,431 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+)
+
+// Node is a part of a parsed boolean query
+type Node interface {
+	fmt.Stringer
+
+	// Returns the sorted ids of the matching documents.
+	// If the node has no meaning (e.g. it's a stop word) ok is false
+	// and the node should be ignored by its parent.
+	evaluate(e *Engine) (docs []int32, ok bool)
+}
+
+type Term struct {
+	Word string
+}
+
+// Phrase matches documents containing its terms one right after the other
+type Phrase struct {
+	Text string
+}
+
+// Near matches documents in which the two terms are at most Distance terms apart,
+// in any order
+type Near struct {
+	Left     *Term
+	Right    *Term
+	Distance int32
+}
+
+// Wildcard matches the documents containing any term which matches the pattern,
+// see trie.WalkPattern
+type Wildcard struct {
+	Pattern string
+}
+
+// Fuzzy matches the documents containing any term at most Distance edits away from the word
+type Fuzzy struct {
+	Word     string
+	Distance int
+}
+
+type And struct {
+	Children []Node
+}
+
+type Or struct {
+	Children []Node
+}
+
+type Not struct {
+	Child Node
+}
+
+type Match struct {
+	DocID    int32
+	Document *indices.DocumentInfo
+}
+
+const DefaultMaxExpansions = 100
+
+// Engine evaluates boolean queries over the inverse index.
+// It never modifies the index, so many engines can share one. The index can be
+// a memory mapped one, then only the posting lists of the query terms are decoded.
+type Engine struct {
+	// A wildcard is expanded to at most this many terms, the ones in the most documents
+	MaxExpansions int
+
+	index     indices.Searchable
+	tokeniser processing.Tokeniser
+}
+
+func NewEngine(index indices.Searchable, tokeniser processing.Tokeniser) *Engine {
+	return &Engine{
+		MaxExpansions: DefaultMaxExpansions,
+		index:         index,
+		tokeniser:     tokeniser,
+	}
+}
+
+func (e *Engine) Search(text string) ([]Match, error) {
+	node, err := Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse query: %s", err)
+	}
+
+	docs := e.Evaluate(node)
+
+	matches := make([]Match, len(docs))
+	for i, docID := range docs {
+		doc, err := e.index.Document(docID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read document: %s", err)
+		}
+		matches[i] = Match{
+			DocID:    docID,
+			Document: &doc,
+		}
+	}
+
+	return matches, nil
+}
+
+// Evaluate returns the sorted ids of the documents matching the query
+func (e *Engine) Evaluate(node Node) []int32 {
+	docs, _ := node.evaluate(e)
+	return docs
+}
+
+// Runs the word through the same normalisation as the documents at index time.
+// Stop words produce no terms.
+func (e *Engine) normalise(word string) []string {
+	var terms []string
+	e.tokeniser.GetTerms(word, func(term string) {
+		terms = append(terms, term)
+	})
+	return terms
+}
+
+// Expand returns the terms in the dictionary matching the wildcard pattern.
+// Wildcards can't be stemmed, so the pattern is only lower cased.
+// If there are more than MaxExpansions matches only the ones in the most documents are kept.
+func (e *Engine) Expand(pattern string) []string {
+	type expansion struct {
+		term      string
+		frequency int
+	}
+
+	var expansions []expansion
+	e.index.WalkPattern([]byte(strings.ToLower(pattern)), func(term []byte, termID int32) {
+		if int(termID) < e.index.NumTerms() && e.index.DocumentFrequency(termID) > 0 {
+			expansions = append(expansions, expansion{
+				term:      string(term),
+				frequency: e.index.DocumentFrequency(termID),
+			})
+		}
+	})
+
+	sort.Slice(expansions, func(i, j int) bool {
+		if expansions[i].frequency != expansions[j].frequency {
+			return expansions[i].frequency > expansions[j].frequency
+		}
+		return expansions[i].term < expansions[j].term
+	})
+
+	if len(expansions) > e.MaxExpansions {
+		expansions = expansions[:e.MaxExpansions]
+	}
+
+	terms := make([]string, len(expansions))
+	for i := range expansions {
+		terms[i] = expansions[i].term
+	}
+	return terms
+}
+
+// Returns cursors over the lists of the terms or nil if one of them isn't in the index
+func (e *Engine) termCursors(terms []string) []*indices.Cursor {
+	cursors := make([]*indices.Cursor, len(terms))
+	for i := range terms {
+		termID := e.index.Find([]byte(terms[i]))
+		if termID == -1 || int(termID) >= e.index.NumTerms() {
+			return nil
+		}
+		cursors[i] = e.index.TermCursor(termID)
+	}
+	return cursors
+}
+
+func (e *Engine) termDocuments(term string) []int32 {
+	return e.conjunction([]string{term})
+}
+
+// Returns the documents which contain all the terms
+func (e *Engine) conjunction(terms []string) []int32 {
+	cursors := e.termCursors(terms)
+	if cursors == nil {
+		return nil
+	}
+
+	return leapfrog(cursors, func(int32) bool {
+		return true
+	})
+}
+
+// Returns the documents which contain all the terms at positions accepted by match.
+// match gets the positions of each term in the same document
+func (e *Engine) positionalDocuments(terms []string, match func(positions [][]int32) bool) []int32 {
+	cursors := e.termCursors(terms)
+	if cursors == nil {
+		return nil
+	}
+
+	positions := make([][]int32, len(cursors))
+	return leapfrog(cursors, func(int32) bool {
+		for i := range cursors {
+			positions[i] = cursors[i].Posting().Positions
+		}
+		return match(positions)
+	})
+}
+
+// Returns the documents which are in all the lists and are accepted by match,
+// which is called while all the cursors are on the document.
+// The shortest list picks the candidates and the rest only advance to them.
+func leapfrog(cursors []*indices.Cursor, match func(docID int32) bool) []int32 {
+	ordered := make([]*indices.Cursor, len(cursors))
+	copy(ordered, cursors)
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].Len() < ordered[j].Len()
+	})
+
+	docs := make([]int32, 0, ordered[0].Len())
+	if !ordered[0].Next() {
+		return docs
+	}
+	target := ordered[0].Posting().Index
+
+	for {
+		inAll := true
+		for _, cursor := range ordered {
+			if !cursor.Advance(target) {
+				return docs
+			}
+			if index := cursor.Posting().Index; index > target {
+				target = index
+				inAll = false
+				break
+			}
+		}
+
+		if !inAll {
+			continue
+		}
+
+		if match(target) {
+			docs = append(docs, target)
+		}
+
+		if !ordered[0].Next() {
+			return docs
+		}
+		target = ordered[0].Posting().Index
+	}
+}
+
+func (e *Engine) allDocuments() []int32 {
+	docs := make([]int32, 0, e.index.Len())
+	for docID := int32(0); docID < int32(e.index.NumDocuments()); docID++ {
+		if !e.index.IsDeleted(docID) {
+			docs = append(docs, docID)
+		}
+	}
+	return docs
+}
+
+// A word which the tokeniser splits into several terms is matched as a phrase
+func (t *Term) evaluate(e *Engine) ([]int32, bool) {
+	return evaluatePhrase(e, t.Word)
+}
+
+func (p *Phrase) evaluate(e *Engine) ([]int32, bool) {
+	return evaluatePhrase(e, p.Text)
+}
+
+func evaluatePhrase(e *Engine, text string) ([]int32, bool) {
+	terms := e.normalise(text)
+	if len(terms) == 0 {
+		return nil, false
+	}
+
+	if len(terms) == 1 {
+		return e.termDocuments(terms[0]), true
+	}
+
+	return e.positionalDocuments(terms, isPhrase), true
+}
+
+func (w *Wildcard) evaluate(e *Engine) ([]int32, bool) {
+	var docs []int32
+	for _, term := range e.Expand(w.Pattern) {
+		docs = union(docs, e.termDocuments(term))
+	}
+	return docs, true
+}
+
+func (n *Near) evaluate(e *Engine) ([]int32, bool) {
+	left := e.normalise(n.Left.Word)
+	right := e.normalise(n.Right.Word)
+
+	if len(left) != 1 || len(right) != 1 {
+		// one of the sides is a stop word (or several terms), so there's no proximity to check
+		return (&And{Children: []Node{n.Left, n.Right}}).evaluate(e)
+	}
+
+	return e.positionalDocuments([]string{left[0], right[0]}, func(positions [][]int32) bool {
+		return areNear(positions[0], positions[1], n.Distance)
+	}), true
+}
+
+func (a *And) evaluate(e *Engine) ([]int32, bool) {
+	var docs []int32
+	var excluded []int32
+	// single terms are intersected together with cursors
+	var terms []string
+	included := false
+	ok := false
+
+	for _, child := range a.Children {
+		if term, isTerm := child.(*Term); isTerm {
+			if normalised := e.normalise(term.Word); len(normalised) == 1 {
+				terms = append(terms, normalised[0])
+				ok = true
+				continue
+			}
+		}
+
+		if not, isNot := child.(*Not); isNot {
+			childDocs, childOk := not.Child.evaluate(e)
+			if childOk {
+				excluded = union(excluded, childDocs)
+				ok = true
+			}
+			continue
+		}
+
+		childDocs, childOk := child.evaluate(e)
+		if !childOk {
+			continue
+		}
+
+		if included {
+			docs = intersect(docs, childDocs)
+		} else {
+			docs = childDocs
+			included = true
+		}
+		ok = true
+	}
+
+	if !ok {
+		return nil, false
+	}
+
+	if len(terms) > 0 {
+		if included {
+			docs = intersect(docs, e.conjunction(terms))
+		} else {
+			docs = e.conjunction(terms)
+			included = true
+		}
+	}
+
+	if !included {
+		docs = e.allDocuments()
+	}
+
+	return difference(docs, excluded), true
+}
+
+func (o *Or) evaluate(e *Engine) ([]int32, bool) {
+	var docs []int32
+	ok := false
+
+	for _, child := range o.Children {
+		childDocs, childOk := child.evaluate(e)
+		if childOk {
+			docs = union(docs, childDocs)
+			ok = true
+		}
+	}
+
+	return docs, ok
+}
+
+func (n *Not) evaluate(e *Engine) ([]int32, bool) {
+	docs, ok := n.Child.evaluate(e)
+	if !ok {
+		return nil, false
+	}
+
+	return difference(e.allDocuments(), docs), true
+}
+
+func (t *Term) String() string {
+	return t.Word
+}
+
+func (p *Phrase) String() string {
+	return fmt.Sprintf("%q", p.Text)
+}
+
+func (w *Wildcard) String() string {
+	return w.Pattern
+}
+
+func (f *Fuzzy) String() string {
+	return fmt.Sprintf("%s~%d", f.Word, f.Distance)
+}
+
+func (n *Near) String() string {
+	return fmt.Sprintf("(%s NEAR/%d %s)", n.Left, n.Distance, n.Right)
+}
+
+func (a *And) String() string {
+	return joinNodes(a.Children, " AND ")
+}
+
+func (o *Or) String() string {
+	return joinNodes(o.Children, " OR ")
+}
+
+func (n *Not) String() string {
+	return "NOT " + n.Child.String()
+}
+
+func joinNodes(nodes []Node, separator string) string {
+	parts := make([]string, len(nodes))
+	for i := range nodes {
+		parts[i] = nodes[i].String()
+	}
+	return "(" + strings.Join(parts, separator) + ")"
+}
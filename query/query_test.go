@@ -0,0 +1,166 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing/processingtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTokeniser = processingtest.NewTokeniser("the", "of")
+
+func makeIndex(texts ...string) *indices.TotalIndex {
+	ti := indices.NewTotalIndex()
+
+	for i, text := range texts {
+		doc := indices.NewInfoAndTerms()
+		doc.Name = texts[i]
+		testTokeniser.GetTerms(text, doc.AddTerm)
+		ti.Add(doc)
+	}
+
+	return ti
+}
+
+func search(t *testing.T, e *Engine, text string) []int32 {
+	matches, err := e.Search(text)
+	if err != nil {
+		t.Fatalf("unable to search for %s: %s", text, err)
+	}
+
+	docs := make([]int32, len(matches))
+	for i := range matches {
+		docs[i] = matches[i].DocID
+	}
+	return docs
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine(makeIndex(
+		"oil prices rise",
+		"oil exports fall",
+		"interest rates rise",
+		"the price of gold",
+	), testTokeniser)
+
+	assert.Equal([]int32{0, 1}, search(t, e, "oil"))
+	assert.Equal([]int32{0, 1}, search(t, e, "OIL"))
+	assert.Equal([]int32{0}, search(t, e, "oil AND rise"))
+	assert.Equal([]int32{0}, search(t, e, "oil rise"))
+	assert.Equal([]int32{0, 1, 2}, search(t, e, "oil OR rise"))
+	assert.Equal([]int32{1}, search(t, e, "oil AND NOT rise"))
+	assert.Equal([]int32{1, 3}, search(t, e, "NOT rise"))
+	assert.Equal([]int32{1, 2}, search(t, e, "(oil OR interest) AND NOT prices"))
+	assert.Equal([]int32{}, search(t, e, "oil AND gold"))
+	assert.Equal([]int32{}, search(t, e, "missing"))
+
+	// stop words are ignored, like at index time
+	assert.Equal([]int32{3}, search(t, e, "the gold"))
+	assert.Empty(search(t, e, "the"))
+}
+
+func TestSearch_Phrase(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine(makeIndex(
+		"interest rate rises",
+		"rate of interest",
+		"the rate rose and interest fell",
+		"interest rate interest rate",
+		"bank of england",
+	), testTokeniser)
+
+	assert.Equal([]int32{0, 3}, search(t, e, `"interest rate"`))
+	assert.Equal([]int32{1, 3}, search(t, e, `"rate interest"`))
+	assert.Equal([]int32{0}, search(t, e, `"interest rate rises"`))
+	assert.Equal([]int32{4}, search(t, e, `"bank of england"`))
+	assert.Equal([]int32{4}, search(t, e, `"bank england"`))
+	assert.Equal([]int32{0, 1, 2, 3}, search(t, e, `"interest"`))
+	assert.Equal([]int32{1, 2}, search(t, e, `interest NOT "interest rate"`))
+
+	assert.Equal([]int32{0, 1, 3}, search(t, e, "interest NEAR/1 rate"))
+	assert.Equal([]int32{0, 1, 3}, search(t, e, "rate NEAR/2 interest"))
+	assert.Equal([]int32{0, 1, 2, 3}, search(t, e, "rate NEAR/3 interest"))
+	assert.Equal([]int32{0}, search(t, e, "interest NEAR/2 rises"))
+	assert.Equal([]int32{}, search(t, e, "interest NEAR/1 rises"))
+}
+
+func TestSearch_Wildcard(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine(makeIndex(
+		"oil prices",
+		"oils and oilseed",
+		"bank rates",
+		"bonk",
+		"banks oil",
+	), testTokeniser)
+
+	assert.Equal([]int32{0, 1, 4}, search(t, e, "oil*"))
+	assert.Equal([]int32{0, 1, 4}, search(t, e, "OIL*"))
+	assert.Equal([]int32{2, 3}, search(t, e, "b?nk"))
+	assert.Equal([]int32{2, 4}, search(t, e, "bank*"))
+	assert.Equal([]int32{4}, search(t, e, "bank* AND oil*"))
+	assert.Equal([]int32{0, 2, 3, 4}, search(t, e, "NOT oil?*"))
+	assert.Equal([]int32{}, search(t, e, "x*"))
+
+	assert.ElementsMatch([]string{"oil", "oils", "oilseed"}, e.Expand("oil*"))
+
+	// oil is in two documents, so it wins over oils and oilseed
+	e.MaxExpansions = 1
+	assert.Equal([]string{"oil"}, e.Expand("oil*"))
+	assert.Equal([]int32{0, 4}, search(t, e, "oil*"))
+}
+
+func TestSearch_Documents(t *testing.T) {
+	assert := assert.New(t)
+
+	e := NewEngine(makeIndex("oil prices rise", "oil exports fall"), testTokeniser)
+
+	matches, err := e.Search("exports")
+	assert.Nil(err)
+	assert.Len(matches, 1)
+	assert.Equal("oil exports fall", matches[0].Document.Name)
+
+	_, err = e.Search("oil AND (rise")
+	assert.NotNil(err)
+}
+
+func TestSearch_LongLists(t *testing.T) {
+	assert := assert.New(t)
+
+	// "common" is in every document, "rare" in every hundredth, "often" in every third
+	texts := make([]string, 1000)
+	var rare, rareAndOften []int32
+	for i := range texts {
+		texts[i] = "common"
+		if i%3 == 0 {
+			texts[i] += " often"
+		}
+		if i%100 == 0 {
+			texts[i] = "rare " + texts[i]
+			rare = append(rare, int32(i))
+			if i%3 == 0 {
+				rareAndOften = append(rareAndOften, int32(i))
+			}
+		}
+	}
+
+	ti := makeIndex(texts...)
+	ti.Finalise()
+	e := NewEngine(ti, testTokeniser)
+
+	check := func() {
+		assert.Equal(rare, search(t, e, "common rare"))
+		assert.Equal(rareAndOften, search(t, e, "rare AND often AND common"))
+		assert.Equal(rare, search(t, e, "\"rare common\""))
+		assert.Equal([]int32{}, search(t, e, "\"common rare\""))
+	}
+
+	check()
+	ti.Inverse.Compress()
+	check()
+}
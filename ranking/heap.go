@@ -0,0 +1,78 @@
+package ranking
+
+import (
+	"container/heap"
+	"sort"
+)
+
+type Result struct {
+	DocID int32
+	Score float64
+}
+
+// Orders results from worst to best, ties are broken by preferring the smaller document id
+func worse(a, b Result) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.DocID > b.DocID
+}
+
+type resultHeap []Result
+
+func (h resultHeap) Len() int            { return len(h) }
+func (h resultHeap) Less(i, j int) bool  { return worse(h[i], h[j]) }
+func (h resultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(Result)) }
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// TopK keeps the k best results seen so far.
+// The worst of them is at the top of a min heap, so it can be replaced in O(log k)
+type TopK struct {
+	k       int
+	results resultHeap
+}
+
+func NewTopK(k int) *TopK {
+	return &TopK{
+		k:       k,
+		results: make(resultHeap, 0, k),
+	}
+}
+
+func (t *TopK) Push(result Result) {
+	if t.k <= 0 {
+		return
+	}
+
+	if len(t.results) < t.k {
+		heap.Push(&t.results, result)
+		return
+	}
+
+	if worse(t.results[0], result) {
+		t.results[0] = result
+		heap.Fix(&t.results, 0)
+	}
+}
+
+// Returns the score a new result has to beat in order to get in
+// or false if there are still less than k results
+func (t *TopK) Threshold() (float64, bool) {
+	if len(t.results) < t.k || t.k <= 0 {
+		return 0, false
+	}
+	return t.results[0].Score, true
+}
+
+// Results returns the kept results from best to worst
+func (t *TopK) Results() []Result {
+	results := append([]Result(nil), t.results...)
+	sort.Slice(results, func(i, j int) bool { return worse(results[j], results[i]) })
+	return results
+}
@@ -0,0 +1,106 @@
+package ranking
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+)
+
+// Ranker scores free text queries against the inverse index, which can be a memory mapped one
+type Ranker struct {
+	index     indices.Searchable
+	tokeniser processing.Tokeniser
+	scorer    Scorer
+
+	// upper bounds of the score contributions of the terms, see maxScores
+	bounds     []float64
+	boundsOnce sync.Once
+}
+
+type queryTerm struct {
+	termID int32
+	count  int32
+}
+
+func NewRanker(index indices.Searchable, tokeniser processing.Tokeniser, scorer Scorer) *Ranker {
+	return &Ranker{
+		index:     index,
+		tokeniser: tokeniser,
+		scorer:    scorer,
+	}
+}
+
+// Normalises the query like a document and counts its terms,
+// terms which aren't in the index are dropped
+func (r *Ranker) queryTerms(text string) []queryTerm {
+	counts := make(map[int32]int32)
+	r.tokeniser.GetTerms(text, func(term string) {
+		termID := r.index.Find([]byte(term))
+		if termID != -1 && int(termID) < r.index.NumTerms() {
+			counts[termID] += 1
+		}
+	})
+
+	terms := make([]queryTerm, 0, len(counts))
+	for termID, count := range counts {
+		terms = append(terms, queryTerm{termID: termID, count: count})
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i].termID < terms[j].termID })
+
+	return terms
+}
+
+// SearchExhaustive is like Search, but scores every posting of the query terms term at a time
+func (r *Ranker) SearchExhaustive(text string, k int) []Result {
+	scores := make(map[int32]float64)
+
+	for _, term := range r.queryTerms(text) {
+		weight := r.scorer.QueryWeight(term.termID, term.count)
+		cursor := r.index.TermCursor(term.termID)
+		for cursor.Next() {
+			scores[cursor.Posting().Index] += weight * r.scorer.Score(term.termID, cursor.Posting())
+		}
+	}
+
+	top := NewTopK(k)
+	for docID, score := range scores {
+		top.Push(Result{DocID: docID, Score: score})
+	}
+
+	return top.Results()
+}
+
+// Count returns the number of documents containing any of the query terms, which is
+// how many documents Search would return if k wasn't limiting them.
+// It goes over every posting of the terms, EstimateCount doesn't
+func (r *Ranker) Count(text string) int {
+	matches := make([]uint64, (r.index.NumDocuments()+63)/64)
+	count := 0
+
+	for _, term := range r.queryTerms(text) {
+		cursor := r.index.TermCursor(term.termID)
+		for cursor.Next() {
+			docID := cursor.Posting().Index
+			if matches[docID/64]&(1<<uint(docID%64)) == 0 {
+				matches[docID/64] |= 1 << uint(docID%64)
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// EstimateCount returns the largest document frequency of the query terms,
+// a lower bound of Count which doesn't need the postings
+func (r *Ranker) EstimateCount(text string) int {
+	estimate := 0
+	for _, term := range r.queryTerms(text) {
+		if frequency := r.index.DocumentFrequency(term.termID); frequency > estimate {
+			estimate = frequency
+		}
+	}
+	return estimate
+}
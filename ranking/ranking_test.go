@@ -0,0 +1,209 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing/processingtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTokeniser = processingtest.NewTokeniser("the")
+
+func makeIndex(texts ...string) *indices.TotalIndex {
+	ti := indices.NewTotalIndex()
+
+	for _, text := range texts {
+		doc := indices.NewInfoAndTerms()
+		doc.Name = text
+		testTokeniser.GetTerms(text, func(term string) {
+			doc.TermsAndCounts.PutLambda([]byte(term), func(x int32) int32 { return x + 1 }, 1)
+			doc.Length += 1
+		})
+		ti.Add(doc)
+	}
+
+	return ti
+}
+
+func docIDs(results []Result) []int32 {
+	ids := make([]int32, len(results))
+	for i := range results {
+		ids[i] = results[i].DocID
+	}
+	return ids
+}
+
+var texts = []string{
+	"oil prices rise as oil exports fall",
+	"gold prices rise",
+	"oil",
+	"the bank raises interest rates",
+	"interest in oil and gold is low while the prices of wheat and corn and coffee rise",
+}
+
+func TestTopK(t *testing.T) {
+	assert := assert.New(t)
+
+	top := NewTopK(3)
+	for i, score := range []float64{0.5, 3, 1, 0.1, 2, 1} {
+		top.Push(Result{DocID: int32(i), Score: score})
+	}
+
+	assert.Equal([]Result{{DocID: 1, Score: 3}, {DocID: 4, Score: 2}, {DocID: 2, Score: 1}}, top.Results())
+
+	threshold, ok := top.Threshold()
+	assert.True(ok)
+	assert.Equal(float64(1), threshold)
+
+	_, ok = NewTopK(2).Threshold()
+	assert.False(ok)
+}
+
+func TestBM25(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeIndex(texts...)
+	r := NewRanker(ti, testTokeniser, NewBM25(ti, DefaultK1, DefaultB))
+
+	// the short document only containing oil and the one with oil twice beat the long one
+	assert.Equal([]int32{2, 0, 4}, docIDs(r.Search("oil", 10)))
+	assert.Equal([]int32{2, 0}, docIDs(r.Search("oil", 2)))
+	assert.Equal([]int32{1, 4, 0}, docIDs(r.Search("gold prices", 10)))
+	assert.Empty(r.Search("nothing", 10))
+	assert.Empty(r.Search("the", 10))
+	assert.Equal(3, r.Count("oil"))
+	assert.Equal(4, r.Count("gold prices oil"))
+	assert.Equal(0, r.Count("nothing"))
+	assert.Equal(3, r.EstimateCount("gold prices oil"))
+	assert.Equal(0, r.EstimateCount("nothing"))
+
+	// idf of a term in 3 out of 5 documents
+	results := r.Search("oil", 1)
+	idf := math.Log(1 + (5-3+0.5)/(3+0.5))
+	averageLength := float64(7+3+1+4+16) / 5
+	expected := idf * 1 * (DefaultK1 + 1) / (1 + DefaultK1*(1-DefaultB+DefaultB*1/averageLength))
+	assert.InDelta(expected, results[0].Score, 0.00001)
+}
+
+func TestTFIDF(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeIndex(texts...)
+	r := NewRanker(ti, testTokeniser, NewTFIDF(ti))
+
+	// "oil" alone is exactly the query direction
+	results := r.Search("oil", 10)
+	assert.Equal([]int32{2, 0, 4}, docIDs(results))
+	assert.InDelta(ti.IDF(ti.Dictionary.Find([]byte("oil"))), results[0].Score, 0.00001)
+
+	assert.Equal([]int32{3, 4}, docIDs(r.Search("interest rates", 10)))
+}
+
+// Documents and queries with Zipf distributed words, so there are both very common and rare terms
+func makeRandomTexts(random *rand.Rand, count int, length int) []string {
+	zipf := rand.NewZipf(random, 1.1, 2, 500)
+
+	texts := make([]string, count)
+	for i := range texts {
+		words := make([]string, 1+random.Intn(length))
+		for j := range words {
+			words[j] = fmt.Sprintf("w%d", zipf.Uint64())
+		}
+		texts[i] = strings.Join(words, " ")
+	}
+	return texts
+}
+
+func TestSearch_SameAsExhaustive(t *testing.T) {
+	assert := assert.New(t)
+
+	random := rand.New(rand.NewSource(7))
+	ti := makeIndex(makeRandomTexts(random, 2000, 40)...)
+	ti.Finalise()
+	queries := makeRandomTexts(random, 50, 5)
+
+	check := func(scorer Scorer) {
+		r := NewRanker(ti, testTokeniser, scorer)
+		for _, query := range queries {
+			for _, k := range []int{1, 3, 10, 100} {
+				assert.Equal(r.SearchExhaustive(query, k), r.Search(query, k), "%s query %q k %d", scorer.Name(), query, k)
+			}
+		}
+	}
+
+	check(NewBM25(ti, DefaultK1, DefaultB))
+	check(NewTFIDF(ti))
+
+	// stored bounds and compressed lists
+	bm25 := NewBM25(ti, 2, 0.5)
+	StoreMaxScores(ti, bm25)
+	ti.Inverse.Compress()
+	check(bm25)
+}
+
+func TestSearch_Concurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := makeIndex(texts...)
+	ti.Finalise()
+	r := NewRanker(ti, testTokeniser, NewBM25(ti, DefaultK1, DefaultB))
+	expected := r.SearchExhaustive("oil prices", 3)
+
+	// the bounds are computed by the first search, which mustn't race with the others
+	results := make(chan []Result)
+	for i := 0; i < 4; i++ {
+		go func() { results <- r.Search("oil prices", 3) }()
+	}
+	for i := 0; i < 4; i++ {
+		assert.Equal(expected, <-results)
+	}
+}
+
+func TestSearch_NegativeIDF(t *testing.T) {
+	assert := assert.New(t)
+
+	// reuter is in every document, so its tf-idf weight and scores are negative
+	random := rand.New(rand.NewSource(3))
+	texts := makeRandomTexts(random, 200, 10)
+	for i := range texts {
+		texts[i] += " reuter"
+	}
+	ti := makeIndex(texts...)
+	ti.Finalise()
+	assert.True(ti.IDF(ti.Dictionary.Find([]byte("reuter"))) < 0)
+
+	r := NewRanker(ti, testTokeniser, NewTFIDF(ti))
+	for _, query := range []string{"reuter", "reuter w2", "w3 reuter w5"} {
+		for _, k := range []int{1, 5, 50} {
+			assert.Equal(r.SearchExhaustive(query, k), r.Search(query, k), "query %q k %d", query, k)
+		}
+	}
+}
+
+func benchmarkSearch(b *testing.B, search func(r *Ranker, query string) []Result) {
+	random := rand.New(rand.NewSource(7))
+	ti := makeIndex(makeRandomTexts(random, 20000, 100)...)
+	ti.Finalise()
+	queries := makeRandomTexts(random, 100, 5)
+
+	r := NewRanker(ti, testTokeniser, NewBM25(ti, DefaultK1, DefaultB))
+	r.maxScores()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		search(r, queries[i%len(queries)])
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	benchmarkSearch(b, func(r *Ranker, query string) []Result { return r.Search(query, 10) })
+}
+
+func BenchmarkSearchExhaustive(b *testing.B) {
+	benchmarkSearch(b, func(r *Ranker, query string) []Result { return r.SearchExhaustive(query, 10) })
+}
@@ -0,0 +1,134 @@
+package ranking
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/bitterfly/search/indices"
+)
+
+const (
+	DefaultK1 = 1.2
+	DefaultB  = 0.75
+)
+
+// Scorer splits a ranking function into per term contributions,
+// so that the score of a document is the sum over the query terms of
+// QueryWeight(term) * Score(term, posting of the term in the document)
+type Scorer interface {
+	// Name identifies the scorer together with its parameters
+	Name() string
+	QueryWeight(termID int32, count int32) float64
+	Score(termID int32, posting *indices.Posting) float64
+}
+
+// TFIDF is the cosine similarity between tf-idf vectors, where tf is normalised
+// by the document length like in kmeans.
+// The query vector isn't normalised since that doesn't change the ranking.
+type TFIDF struct {
+	index indices.Searchable
+	norms []float64
+}
+
+func NewTFIDF(index indices.Searchable) *TFIDF {
+	s := &TFIDF{
+		index: index,
+		norms: make([]float64, index.NumDocuments()),
+	}
+
+	for docID := int32(0); docID < int32(len(s.norms)); docID++ {
+		if index.IsDeleted(docID) {
+			continue
+		}
+
+		sum := float64(0)
+		cursor := index.DocumentCursor(docID)
+		for cursor.Next() {
+			weight := s.weight(docID, cursor.Posting().Count) * indices.IDF(index, cursor.Posting().Index)
+			sum += weight * weight
+		}
+		s.norms[docID] = math.Sqrt(sum)
+	}
+
+	return s
+}
+
+func (s *TFIDF) weight(docID int32, count int32) float64 {
+	length := s.index.DocumentLength(docID)
+	if length == 0 {
+		return 0
+	}
+	return float64(count) / float64(length)
+}
+
+func (s *TFIDF) Name() string {
+	return "tfidf"
+}
+
+func (s *TFIDF) QueryWeight(termID int32, count int32) float64 {
+	return float64(count) * indices.IDF(s.index, termID)
+}
+
+func (s *TFIDF) Score(termID int32, posting *indices.Posting) float64 {
+	norm := s.norms[posting.Index]
+	if norm == 0 {
+		return 0
+	}
+	return s.weight(posting.Index, posting.Count) * indices.IDF(s.index, termID) / norm
+}
+
+// BM25 is the Okapi BM25 ranking function with term frequency saturation k1
+// and length normalisation b
+type BM25 struct {
+	K1 float64
+	B  float64
+
+	index         indices.Searchable
+	averageLength float64
+}
+
+func NewBM25(index indices.Searchable, k1, b float64) *BM25 {
+	total := float64(0)
+	for docID := int32(0); docID < int32(index.NumDocuments()); docID++ {
+		if !index.IsDeleted(docID) {
+			total += float64(index.DocumentLength(docID))
+		}
+	}
+
+	averageLength := float64(0)
+	if index.Len() != 0 {
+		averageLength = total / float64(index.Len())
+	}
+
+	return &BM25{
+		K1:            k1,
+		B:             b,
+		index:         index,
+		averageLength: averageLength,
+	}
+}
+
+func (s *BM25) idf(termID int32) float64 {
+	n := float64(s.index.Len())
+	df := float64(s.index.DocumentFrequency(termID))
+	return math.Log(1 + (n-df+0.5)/(df+0.5))
+}
+
+func (s *BM25) Name() string {
+	return fmt.Sprintf("bm25(k1=%g,b=%g)", s.K1, s.B)
+}
+
+func (s *BM25) QueryWeight(termID int32, count int32) float64 {
+	return float64(count)
+}
+
+func (s *BM25) Score(termID int32, posting *indices.Posting) float64 {
+	tf := float64(posting.Count)
+
+	lengthNorm := float64(1)
+	if s.averageLength != 0 {
+		lengthNorm = 1 - s.B + s.B*float64(s.index.DocumentLength(posting.Index))/s.averageLength
+	}
+
+	return s.idf(termID) * tf * (s.K1 + 1) / (tf + s.K1*lengthNorm)
+}
@@ -0,0 +1,157 @@
+package ranking
+
+import (
+	"math"
+	"sort"
+
+	"github.com/bitterfly/search/indices"
+)
+
+// Upper bounds are inflated by this much, since the sum of the bounds is added up in a different
+// order than the score and may round below it
+const boundSlack = 1e-9
+
+// ComputeMaxScores returns for each term the highest absolute value of scorer.Score over its postings.
+// Scores can be negative, tf-idf is for terms in almost every document, and then so is the query weight,
+// so the contribution of a term is at most the absolute value of its weight times this bound
+func ComputeMaxScores(index indices.Searchable, scorer Scorer) []float64 {
+	maxScores := make([]float64, index.NumTerms())
+	for termID := range maxScores {
+		cursor := index.TermCursor(int32(termID))
+		for cursor.Next() {
+			if score := math.Abs(scorer.Score(int32(termID), cursor.Posting())); score > maxScores[termID] {
+				maxScores[termID] = score
+			}
+		}
+	}
+	return maxScores
+}
+
+// StoreMaxScores keeps the upper bounds of the scorer in the index, so they are serialised with it
+// and rankers don't have to compute them on start
+func StoreMaxScores(index *indices.TotalIndex, scorer Scorer) {
+	if index.MaxScores == nil {
+		index.MaxScores = make(map[string][]float64)
+	}
+	index.MaxScores[scorer.Name()] = ComputeMaxScores(index, scorer)
+}
+
+type wandTerm struct {
+	queryTerm
+	cursor *indices.Cursor
+	weight float64
+	// the highest contribution of the term to a document score
+	bound float64
+	// the current document, -1 once the cursor is exhausted
+	docID int32
+}
+
+func (w *wandTerm) move(moved bool) {
+	if moved {
+		w.docID = w.cursor.Posting().Index
+	} else {
+		w.docID = -1
+	}
+}
+
+// Returns the stored upper bounds for the scorer or computes them if they're missing.
+// Only a TotalIndex stores them and drops them whenever its documents change, the length check
+// only catches bounds of another index. They're only looked up once, so that concurrent searches
+// don't race on them, and the index can't change after that
+func (r *Ranker) maxScores() []float64 {
+	r.boundsOnce.Do(func() {
+		if ti, ok := r.index.(*indices.TotalIndex); ok {
+			r.bounds = ti.MaxScores[r.scorer.Name()]
+		}
+		if len(r.bounds) != r.index.NumTerms() {
+			r.bounds = ComputeMaxScores(r.index, r.scorer)
+		}
+	})
+	return r.bounds
+}
+
+// Search returns the k best scoring documents for the query, best first.
+// Documents which don't contain any of the query terms aren't returned.
+//
+// The documents are evaluated one at a time with WAND: the terms are ordered by their current
+// document and a document is only scored if the upper bounds of the terms up to it can beat
+// the k-th best score so far. Otherwise the cursors before it skip ahead.
+// The results are exactly the same as the ones of SearchExhaustive.
+func (r *Ranker) Search(text string, k int) []Result {
+	top := NewTopK(k)
+	if k <= 0 {
+		return top.Results()
+	}
+
+	maxScores := r.maxScores()
+
+	// in order of term id, so that the scores are added up like in SearchExhaustive
+	var terms []*wandTerm
+	for _, term := range r.queryTerms(text) {
+		w := &wandTerm{
+			queryTerm: term,
+			cursor:    r.index.TermCursor(term.termID),
+			weight:    r.scorer.QueryWeight(term.termID, term.count),
+		}
+		w.bound = math.Abs(w.weight) * maxScores[term.termID] * (1 + boundSlack)
+		w.move(w.cursor.Next())
+		if w.docID != -1 {
+			terms = append(terms, w)
+		}
+	}
+
+	ordered := append([]*wandTerm(nil), terms...)
+
+	for len(ordered) > 0 {
+		sort.Slice(ordered, func(i, j int) bool { return ordered[i].docID < ordered[j].docID })
+
+		threshold, full := top.Threshold()
+
+		// the first term at which the document could get in
+		pivot := -1
+		bound := float64(0)
+		for i := range ordered {
+			bound += ordered[i].bound
+			if !full || bound >= threshold {
+				pivot = i
+				break
+			}
+		}
+		if pivot == -1 {
+			break
+		}
+
+		pivotDoc := ordered[pivot].docID
+		if ordered[0].docID == pivotDoc {
+			score := float64(0)
+			for _, term := range terms {
+				if term.docID == pivotDoc {
+					score += term.weight * r.scorer.Score(term.termID, term.cursor.Posting())
+				}
+			}
+			top.Push(Result{DocID: pivotDoc, Score: score})
+
+			for _, term := range ordered {
+				if term.docID != pivotDoc {
+					break
+				}
+				term.move(term.cursor.Next())
+			}
+		} else {
+			// no document before the pivot can get in
+			for _, term := range ordered[:pivot] {
+				term.move(term.cursor.Advance(pivotDoc))
+			}
+		}
+
+		live := ordered[:0]
+		for _, term := range ordered {
+			if term.docID != -1 {
+				live = append(live, term)
+			}
+		}
+		ordered = live
+	}
+
+	return top.Results()
+}
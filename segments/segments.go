@@ -0,0 +1,426 @@
+// Package segments keeps an index as a list of immutable segments, each one a TotalIndex
+// in its own file, and a small in-memory segment which new documents are added to.
+//
+// The in-memory segment is written to the directory once it has Options.FlushDocuments documents.
+// Segments of about the same size are merged in the background, so their number stays logarithmic
+// in the number of documents. The live segments are listed in order in the manifest file,
+// which is replaced only after a segment is written, so a crash never leaves half a segment behind.
+// Queries are evaluated on every segment, including the in-memory one.
+package segments
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing"
+	"github.com/bitterfly/search/query"
+)
+
+const manifestName = "segments"
+
+type Options struct {
+	// The in-memory segment is flushed when it has this many documents
+	FlushDocuments int
+	// This many neighbouring segments of about the same size are merged into one
+	MergeFactor int
+
+	// Used to normalise the queries, it has to be the one the documents were tokenised with
+	Tokeniser processing.Tokeniser
+	// Given to every new segment
+	Metadata indices.Metadata
+}
+
+func DefaultOptions(tokeniser processing.Tokeniser) Options {
+	return Options{
+		FlushDocuments: 1000,
+		MergeFactor:    10,
+		Tokeniser:      tokeniser,
+	}
+}
+
+type Segment struct {
+	// File name in the directory, empty for the in-memory segment
+	Name  string
+	Index *indices.TotalIndex
+}
+
+// Index is safe for concurrent use
+type Index struct {
+	dir     string
+	options Options
+
+	mutex    sync.RWMutex
+	memory   *indices.TotalIndex
+	segments []*Segment
+	nextID   int
+
+	// at most one merge runs at a time. The error of the last failed one is returned by the next
+	// Flush or Close, the merge is tried again after the next flush
+	merging  bool
+	merges   sync.WaitGroup
+	mergeErr error
+}
+
+type Match struct {
+	Segment  *Segment
+	DocID    int32
+	Document *indices.DocumentInfo
+}
+
+// Open loads the segments listed in the manifest of the directory, which is created if it doesn't exist
+func Open(dir string, options Options) (*Index, error) {
+	if options.FlushDocuments < 1 || options.MergeFactor < 2 {
+		return nil, fmt.Errorf("invalid options: flush documents %d, merge factor %d", options.FlushDocuments, options.MergeFactor)
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create directory: %s", err)
+	}
+
+	ix := &Index{
+		dir:     dir,
+		options: options,
+	}
+	ix.resetMemory()
+
+	names, err := readManifest(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		var id int
+		_, err = fmt.Sscanf(name, "%d", &id)
+		if err != nil {
+			return nil, fmt.Errorf("invalid segment name %s", name)
+		}
+		if id >= ix.nextID {
+			ix.nextID = id + 1
+		}
+
+		ti := indices.NewTotalIndex()
+		err = ti.DeserialiseFromFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("unable to load segment %s: %s", name, err)
+		}
+		ix.segments = append(ix.segments, &Segment{Name: name, Index: ti})
+	}
+
+	ix.mutex.Lock()
+	ix.maybeMerge()
+	ix.mutex.Unlock()
+
+	return ix, nil
+}
+
+func (ix *Index) resetMemory() {
+	ix.memory = indices.NewTotalIndex()
+	ix.memory.Metadata = ix.options.Metadata
+}
+
+// Add adds the document to the in-memory segment, flushing it if it's full
+func (ix *Index) Add(d *indices.InfoAndTerms) error {
+	if d.TermsAndCounts.Empty() {
+		return fmt.Errorf("document %s is empty", d.Name)
+	}
+
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	ix.memory.Add(d)
+	if len(ix.memory.Documents) < ix.options.FlushDocuments {
+		return nil
+	}
+	return ix.flush()
+}
+
+// Flush writes the in-memory segment to the directory and retries a failed merge.
+// It returns the error of a background merge which failed since the last Flush, if any
+func (ix *Index) Flush() error {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	err := ix.flush()
+	if err != nil {
+		return err
+	}
+
+	err = ix.mergeErr
+	ix.mergeErr = nil
+	return err
+}
+
+func (ix *Index) flush() error {
+	if len(ix.memory.Documents) == 0 {
+		ix.maybeMerge()
+		return nil
+	}
+
+	ix.memory.Finalise()
+	segment := &Segment{Name: ix.newName(), Index: ix.memory}
+
+	err := ix.writeSegment(segment)
+	if err != nil {
+		return err
+	}
+
+	ix.segments = append(ix.segments, segment)
+	err = ix.writeManifest()
+	if err != nil {
+		ix.segments = ix.segments[:len(ix.segments)-1]
+		ix.removeSegment(segment)
+		return err
+	}
+
+	ix.resetMemory()
+	ix.maybeMerge()
+	return nil
+}
+
+// Close flushes the in-memory segment and waits for the merges to finish
+func (ix *Index) Close() error {
+	err := ix.Flush()
+	ix.merges.Wait()
+	if err != nil {
+		return err
+	}
+	return ix.mergeErr
+}
+
+// Len returns the number of documents in all segments, deleted ones excluded
+func (ix *Index) Len() int {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+
+	length := ix.memory.Len()
+	for _, segment := range ix.segments {
+		length += segment.Index.Len()
+	}
+	return length
+}
+
+// Segments returns the segments on disk, oldest first
+func (ix *Index) Segments() []*Segment {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+
+	return append([]*Segment(nil), ix.segments...)
+}
+
+// Search evaluates the query on every segment. The matches are in the order the documents were added
+func (ix *Index) Search(text string) ([]Match, error) {
+	node, err := query.Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse query: %s", err)
+	}
+
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+
+	segments := make([]*Segment, 0, len(ix.segments)+1)
+	segments = append(segments, ix.segments...)
+	segments = append(segments, &Segment{Index: ix.memory})
+
+	var matches []Match
+	for _, segment := range segments {
+		engine := query.NewEngine(segment.Index, ix.options.Tokeniser)
+		for _, docID := range engine.Evaluate(node) {
+			matches = append(matches, Match{
+				Segment:  segment,
+				DocID:    docID,
+				Document: &segment.Index.Documents[docID],
+			})
+		}
+	}
+
+	return matches, nil
+}
+
+func (ix *Index) newName() string {
+	ix.nextID++
+	return fmt.Sprintf("%08d.gob.gz", ix.nextID-1)
+}
+
+// The segment is written to a temporary file first, so a segment file is always complete
+func (ix *Index) writeSegment(segment *Segment) error {
+	path := filepath.Join(ix.dir, segment.Name)
+
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return fmt.Errorf("unable to create segment: %s", err)
+	}
+
+	err = segment.Index.SerialiseTo(f)
+	if err != nil {
+		f.Close()
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("unable to write segment %s: %s", segment.Name, err)
+	}
+
+	err = f.Close()
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("unable to write segment %s: %s", segment.Name, err)
+	}
+	return nil
+}
+
+// Removes the file of a segment which isn't in the manifest
+func (ix *Index) removeSegment(segment *Segment) {
+	err := os.Remove(filepath.Join(ix.dir, segment.Name))
+	if err != nil {
+		log.Printf("unable to remove segment %s: %s", segment.Name, err)
+	}
+}
+
+func (ix *Index) writeManifest() error {
+	path := filepath.Join(ix.dir, manifestName)
+
+	var manifest strings.Builder
+	for _, segment := range ix.segments {
+		manifest.WriteString(segment.Name)
+		manifest.WriteString("\n")
+	}
+
+	err := ioutil.WriteFile(path+".tmp", []byte(manifest.String()), 0644)
+	if err != nil {
+		return fmt.Errorf("unable to write manifest: %s", err)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+func readManifest(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to open manifest: %s", err)
+	}
+	defer f.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			names = append(names, scanner.Text())
+		}
+	}
+	if scanner.Err() != nil {
+		return nil, fmt.Errorf("unable to read manifest: %s", scanner.Err())
+	}
+
+	return names, nil
+}
+
+// A segment with up to FlushDocuments documents is on level 0,
+// every level holds MergeFactor times more documents than the previous one
+func (ix *Index) level(segment *Segment) int {
+	level := 0
+	for size := ix.options.FlushDocuments; len(segment.Index.Documents) > size; size *= ix.options.MergeFactor {
+		level++
+	}
+	return level
+}
+
+// Starts merging the oldest MergeFactor neighbouring segments on the same level, if there are such.
+// Only neighbours are merged, so that the documents stay in the order they were added.
+// The mutex has to be locked
+func (ix *Index) maybeMerge() {
+	if ix.merging {
+		return
+	}
+
+	factor := ix.options.MergeFactor
+	for start := 0; start+factor <= len(ix.segments); start++ {
+		level := ix.level(ix.segments[start])
+
+		end := start + 1
+		for end < start+factor && ix.level(ix.segments[end]) == level {
+			end++
+		}
+
+		if end == start+factor {
+			ix.merging = true
+			ix.merges.Add(1)
+			go ix.merge(append([]*Segment(nil), ix.segments[start:end]...))
+			return
+		}
+	}
+}
+
+func (ix *Index) merge(run []*Segment) {
+	defer ix.merges.Done()
+
+	merged, err := mergeSegments(run)
+
+	ix.mutex.Lock()
+	segment := &Segment{Name: ix.newName(), Index: merged}
+	ix.mutex.Unlock()
+
+	if err == nil {
+		err = ix.writeSegment(segment)
+	}
+
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	ix.merging = false
+	if err != nil {
+		log.Printf("unable to merge segments, retrying after the next flush: %s", err)
+		ix.mergeErr = err
+		return
+	}
+
+	// only flushes happened in the meantime, which append after the run
+	start := 0
+	for ix.segments[start] != run[0] {
+		start++
+	}
+
+	segments := append([]*Segment(nil), ix.segments[:start]...)
+	segments = append(segments, segment)
+	segments = append(segments, ix.segments[start+len(run):]...)
+
+	old := ix.segments
+	ix.segments = segments
+	err = ix.writeManifest()
+	if err != nil {
+		ix.segments = old
+		ix.removeSegment(segment)
+		log.Printf("unable to merge segments, retrying after the next flush: %s", err)
+		ix.mergeErr = err
+		return
+	}
+
+	for _, s := range run {
+		ix.removeSegment(s)
+	}
+
+	ix.maybeMerge()
+}
+
+// Merges the segments in order, see indices.Merge
+func mergeSegments(run []*Segment) (*indices.TotalIndex, error) {
+	merged := run[0].Index
+	for _, segment := range run[1:] {
+		var err error
+		merged, err = indices.Merge(merged, segment.Index)
+		if err != nil {
+			return nil, fmt.Errorf("unable to merge segment %s: %s", segment.Name, err)
+		}
+	}
+	return merged, nil
+}
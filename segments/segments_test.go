@@ -0,0 +1,167 @@
+package segments
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/processing/processingtest"
+	"github.com/stretchr/testify/assert"
+)
+
+var testTokeniser = processingtest.NewTokeniser("the")
+
+func makeDocument(name string, text string) *indices.InfoAndTerms {
+	doc := indices.NewInfoAndTerms()
+	doc.Name = name
+	testTokeniser.GetTerms(text, doc.AddTerm)
+	return doc
+}
+
+func names(t *testing.T, ix *Index, text string) []string {
+	matches, err := ix.Search(text)
+	if err != nil {
+		t.Fatalf("unable to search for %s: %s", text, err)
+	}
+
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Document.Name)
+	}
+	return names
+}
+
+func TestIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "segments")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	options := DefaultOptions(testTokeniser)
+	options.FlushDocuments = 2
+	options.MergeFactor = 2
+
+	ix, err := Open(dir, options)
+	assert.Nil(err)
+
+	var all []string
+	for i := 0; i < 9; i++ {
+		name := fmt.Sprintf("doc%d", i)
+		all = append(all, name)
+		assert.Nil(ix.Add(makeDocument(name, fmt.Sprintf("the common word%d shared%d", i, i%2))))
+
+		// documents can be found right after they're added
+		assert.Equal([]string{name}, names(t, ix, fmt.Sprintf("word%d", i)))
+		assert.Equal(all, names(t, ix, "common"))
+	}
+	assert.NotNil(ix.Add(makeDocument("empty", "the")))
+
+	assert.Nil(ix.Close())
+	assert.Equal(9, ix.Len())
+
+	// 8 documents are merged into one segment and the last one is flushed on its own
+	segments := ix.Segments()
+	assert.Len(segments, 2)
+	assert.Len(segments[0].Index.Documents, 8)
+	assert.Empty(segments[0].Index.Verify())
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(files, 3)
+
+	assert.Equal([]string{"doc1", "doc3", "doc5", "doc7"}, names(t, ix, "shared1"))
+	assert.Equal([]string{"doc0", "doc2", "doc4", "doc6", "doc8"}, names(t, ix, "shared0 AND common"))
+
+	reopened, err := Open(dir, options)
+	assert.Nil(err)
+	assert.Equal(9, reopened.Len())
+	assert.Equal(all, names(t, reopened, "common"))
+
+	assert.Nil(reopened.Add(makeDocument("doc9", "common")))
+	assert.Nil(reopened.Close())
+	// the two small segments are merged
+	segments = reopened.Segments()
+	assert.Len(segments, 2)
+	assert.Len(segments[1].Index.Documents, 2)
+	assert.Equal(append(all, "doc9"), names(t, reopened, "common"))
+}
+
+// Makes renaming a file to name fail, by putting a directory which isn't empty there
+func block(t *testing.T, dir string, name string) func() {
+	path := filepath.Join(dir, name)
+	os.Remove(path)
+	if err := os.MkdirAll(filepath.Join(path, "block"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return func() { os.RemoveAll(path) }
+}
+
+func fileNames(t *testing.T, dir string) []string {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	return names
+}
+
+func TestIndex_FailedMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "segments")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	options := DefaultOptions(testTokeniser)
+	options.FlushDocuments = 1
+	options.MergeFactor = 2
+
+	ix, err := Open(dir, options)
+	assert.Nil(err)
+
+	// the merged segment can't be written
+	unblock := block(t, dir, "00000002.gob.gz")
+	assert.Nil(ix.Add(makeDocument("doc0", "common")))
+	assert.Nil(ix.Add(makeDocument("doc1", "common")))
+	ix.merges.Wait()
+	assert.Len(ix.Segments(), 2)
+	unblock()
+
+	// the error is reported once and the merge is retried
+	assert.NotNil(ix.Flush())
+	ix.merges.Wait()
+	assert.Len(ix.Segments(), 1)
+	assert.Nil(ix.Flush())
+	assert.Equal([]string{"00000003.gob.gz", manifestName}, fileNames(t, dir))
+
+	// the manifest can't be replaced, so the new segment isn't kept, but its document stays in memory
+	unblock = block(t, dir, manifestName)
+	assert.NotNil(ix.Add(makeDocument("doc2", "common")))
+	assert.Equal([]string{"00000003.gob.gz", manifestName, manifestName + ".tmp"}, fileNames(t, dir))
+	unblock()
+
+	assert.Nil(ix.Flush())
+	assert.Len(ix.Segments(), 2)
+
+	// nor is the merged one
+	unblock = block(t, dir, manifestName)
+	ix.mutex.Lock()
+	ix.merging = true
+	ix.merges.Add(1)
+	ix.mutex.Unlock()
+	ix.merge(ix.Segments())
+	assert.Len(ix.Segments(), 2)
+	assert.Equal([]string{"00000003.gob.gz", "00000005.gob.gz", manifestName, manifestName + ".tmp"}, fileNames(t, dir))
+	unblock()
+
+	assert.NotNil(ix.Close())
+	assert.Equal([]string{"doc0", "doc1", "doc2"}, names(t, ix, "common"))
+}
@@ -0,0 +1,478 @@
+// Package spimi builds an index file in the diskindex format for corpora which don't fit in memory,
+// with single-pass in-memory indexing.
+//
+// The inverse postings of the added documents are collected in memory until they take about
+// Options.MemoryBudget bytes. Then they are written as a run, sorted by term, to a temporary file.
+// The forward lists are written to a temporary file as the documents come. On Close the runs
+// are merged into the final index, one term at a time. Only the dictionaries and the document
+// information are kept in memory for the whole build.
+package spimi
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"unsafe"
+
+	"github.com/bitterfly/search/diskindex"
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/trie"
+)
+
+const DefaultMemoryBudget = 256 << 20
+
+type Options struct {
+	// Approximate number of bytes the postings of a run can take before it's written out.
+	// It counts the run's map, posting slices and positions, with the capacity they grew to.
+	// The dictionaries, the document information and the buffers of the temporary files
+	// aren't counted, they grow with the vocabulary and the number of documents for the whole
+	// build and are never written out before Close
+	MemoryBudget int64
+	// Directory for the temporary files, the system default if empty
+	TempDir string
+}
+
+// Builder is like a TotalIndex which can only be added to, the result is written on Close
+type Builder struct {
+	// Written to the index file, the number of documents and terms are filled in on Close
+	Metadata indices.Metadata
+
+	filename string
+	options  Options
+	tempDir  string
+
+	dictionary trie.BiDictionary
+	classNames trie.BiDictionary
+	documents  []indices.DocumentInfo
+
+	forwardFile *os.File
+	forward     *bufio.Writer
+
+	// the inverse postings of the current run by term
+	run      map[int32][]indices.Posting
+	runBytes int64
+	runs     []string
+}
+
+var postingSize = int64(unsafe.Sizeof(indices.Posting{}))
+
+// NewBuilder starts building an index, which is written to filename on Close
+func NewBuilder(filename string, options Options) (*Builder, error) {
+	if options.MemoryBudget <= 0 {
+		options.MemoryBudget = DefaultMemoryBudget
+	}
+
+	tempDir, err := ioutil.TempDir(options.TempDir, "spimi")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary directory: %s", err)
+	}
+
+	forwardFile, err := os.Create(filepath.Join(tempDir, "forward"))
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, fmt.Errorf("unable to create temporary file: %s", err)
+	}
+
+	return &Builder{
+		filename:    filename,
+		options:     options,
+		tempDir:     tempDir,
+		dictionary:  *trie.NewBiDictionary(),
+		classNames:  *trie.NewBiDictionary(),
+		forwardFile: forwardFile,
+		forward:     bufio.NewWriterSize(forwardFile, 1<<20),
+		run:         make(map[int32][]indices.Posting),
+	}, nil
+}
+
+// Len returns the number of added documents
+func (b *Builder) Len() int {
+	return len(b.documents)
+}
+
+// Add indexes the document and returns its id, the ids are given like TotalIndex.Add does
+func (b *Builder) Add(d *indices.InfoAndTerms) (int32, error) {
+	if d.TermsAndCounts.Empty() {
+		return -1, fmt.Errorf("document %s is empty", d.Name)
+	}
+
+	docID := int32(len(b.documents))
+
+	var postings []indices.Posting
+	d.TermsAndCounts.Walk(func(term []byte, count int32) {
+		postings = append(postings, indices.Posting{Index: b.dictionary.Get(term), Count: count})
+	})
+	sort.Slice(postings, func(i, j int) bool { return postings[i].Index < postings[j].Index })
+
+	info := indices.DocumentInfo{
+		Name:         d.Name,
+		Classes:      make([]int32, len(d.Classes)),
+		Length:       d.Length,
+		UniqueLength: int32(len(postings)),
+		ClusterID:    -1,
+	}
+	for i := range d.Classes {
+		info.Classes[i] = b.classNames.Get([]byte(d.Classes[i]))
+	}
+	b.documents = append(b.documents, info)
+
+	err := writeRecord(b.forward, indices.EncodePostings(postings))
+	if err != nil {
+		return -1, fmt.Errorf("unable to write forward list: %s", err)
+	}
+
+	for _, posting := range postings {
+		termID := posting.Index
+		list, ok := b.run[termID]
+		if !ok {
+			// the map entry with its key and slice header, about what a map bucket takes per entry
+			b.runBytes += 64
+		}
+
+		// the positions aren't copied, the run keeps the whole array of the document
+		positions := d.Positions[string(b.dictionary.GetInverse(termID))]
+		b.runBytes += 4 * int64(cap(positions))
+
+		oldCap := cap(list)
+		list = append(list, indices.Posting{Index: docID, Count: posting.Count, Positions: positions})
+		b.runBytes += postingSize * int64(cap(list)-oldCap)
+		b.run[termID] = list
+	}
+
+	if b.runBytes >= b.options.MemoryBudget {
+		err = b.flushRun()
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	return docID, nil
+}
+
+// Writes the current run sorted by term: the term id, the length of the encoded list and the list
+func (b *Builder) flushRun() error {
+	if len(b.run) == 0 {
+		return nil
+	}
+
+	termIDs := make([]int32, 0, len(b.run))
+	for termID := range b.run {
+		termIDs = append(termIDs, termID)
+	}
+	sort.Slice(termIDs, func(i, j int) bool { return termIDs[i] < termIDs[j] })
+
+	name := filepath.Join(b.tempDir, fmt.Sprintf("run%d", len(b.runs)))
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("unable to create run: %s", err)
+	}
+	w := bufio.NewWriterSize(f, 1<<20)
+
+	var scratch [binary.MaxVarintLen64]byte
+	for _, termID := range termIDs {
+		_, err = w.Write(scratch[:binary.PutUvarint(scratch[:], uint64(termID))])
+		if err == nil {
+			err = writeRecord(w, indices.EncodePostings(b.run[termID]))
+		}
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("unable to write run: %s", err)
+		}
+	}
+
+	err = w.Flush()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("unable to write run: %s", err)
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("unable to write run: %s", err)
+	}
+
+	b.runs = append(b.runs, name)
+	b.run = make(map[int32][]indices.Posting)
+	b.runBytes = 0
+	return nil
+}
+
+// Close merges the runs into the index file and removes the temporary files
+func (b *Builder) Close() error {
+	defer os.RemoveAll(b.tempDir)
+
+	err := b.forward.Flush()
+	if err == nil {
+		err = b.forwardFile.Close()
+	} else {
+		b.forwardFile.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write forward lists: %s", err)
+	}
+
+	err = b.flushRun()
+	if err != nil {
+		return err
+	}
+
+	w, err := diskindex.Create(b.filename)
+	if err != nil {
+		return err
+	}
+
+	err = b.write(w)
+	if err != nil {
+		w.Abort()
+		return err
+	}
+
+	return w.Close()
+}
+
+func (b *Builder) write(w *diskindex.Writer) error {
+	b.Metadata.Update(len(b.documents), int(b.dictionary.Size))
+
+	err := w.WriteMetadata(b.Metadata)
+	if err != nil {
+		return fmt.Errorf("unable to write metadata: %s", err)
+	}
+
+	err = w.WriteStrings(diskindex.SectionDictionary, dictionaryStrings(&b.dictionary))
+	if err != nil {
+		return fmt.Errorf("unable to write dictionary: %s", err)
+	}
+
+	err = w.WriteStrings(diskindex.SectionClasses, dictionaryStrings(&b.classNames))
+	if err != nil {
+		return fmt.Errorf("unable to write classes: %s", err)
+	}
+
+	err = b.mergeRuns(w)
+	if err != nil {
+		return fmt.Errorf("unable to write inverse index: %s", err)
+	}
+
+	err = b.copyForward(w)
+	if err != nil {
+		return fmt.Errorf("unable to write forward index: %s", err)
+	}
+
+	err = w.WriteDocuments(b.documents)
+	if err != nil {
+		return fmt.Errorf("unable to write documents: %s", err)
+	}
+
+	err = w.WriteCentroids(nil)
+	if err != nil {
+		return fmt.Errorf("unable to write centroids: %s", err)
+	}
+
+	return nil
+}
+
+// Merges the lists of every term from all runs. The runs are in order of document id,
+// so the lists of a term only have to be concatenated in the order of the runs
+func (b *Builder) mergeRuns(w *diskindex.Writer) error {
+	readers := make(runHeap, 0, len(b.runs))
+	defer func() {
+		for _, r := range readers {
+			r.file.Close()
+		}
+	}()
+
+	for i, name := range b.runs {
+		r, err := openRun(name, i)
+		if err != nil {
+			return err
+		}
+		ok, err := r.next()
+		if err != nil {
+			r.file.Close()
+			return err
+		}
+		if ok {
+			readers = append(readers, r)
+		} else {
+			r.file.Close()
+		}
+	}
+	heap.Init(&readers)
+
+	var err error
+	termID := int32(0)
+	writeErr := w.WriteLists(diskindex.SectionInverse, func() ([]byte, bool) {
+		if termID == b.dictionary.Size {
+			return nil, false
+		}
+
+		// the runs have increasing document ids, so their lists are encoded one after the other
+		encoder := indices.NewPostingEncoder()
+		previous := int32(-1)
+		for len(readers) > 0 && readers[0].termID == termID {
+			r := readers[0]
+
+			decoder := indices.NewPostingDecoder(r.list)
+			for decoder.Next() {
+				if decoder.Posting().Index <= previous {
+					err = fmt.Errorf("run %s overlaps the previous runs in term %d", r.file.Name(), termID)
+					return nil, false
+				}
+				previous = decoder.Posting().Index
+				encoder.Add(decoder.Posting())
+			}
+			if decoder.Err() != nil {
+				err = fmt.Errorf("corrupt run %s: %s", r.file.Name(), decoder.Err())
+				return nil, false
+			}
+
+			var ok bool
+			ok, err = r.next()
+			if err != nil {
+				return nil, false
+			}
+			if ok {
+				heap.Fix(&readers, 0)
+			} else {
+				r.file.Close()
+				heap.Pop(&readers)
+			}
+		}
+
+		if encoder.Len() == 0 {
+			err = fmt.Errorf("term %d isn't in any run", termID)
+			return nil, false
+		}
+
+		termID++
+		return encoder.Bytes(), true
+	})
+
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
+
+func (b *Builder) copyForward(w *diskindex.Writer) error {
+	f, err := os.Open(b.forwardFile.Name())
+	if err != nil {
+		return fmt.Errorf("unable to open forward lists: %s", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, 1<<20)
+	docID := 0
+	writeErr := w.WriteLists(diskindex.SectionForward, func() ([]byte, bool) {
+		if docID == len(b.documents) {
+			return nil, false
+		}
+
+		var list []byte
+		list, err = readRecord(r)
+		if err != nil {
+			err = fmt.Errorf("unable to read forward list %d: %s", docID, err)
+			return nil, false
+		}
+
+		docID++
+		return list, true
+	})
+
+	if err != nil {
+		return err
+	}
+	return writeErr
+}
+
+func dictionaryStrings(dictionary *trie.BiDictionary) [][]byte {
+	strings := make([][]byte, dictionary.Size)
+	for id := range strings {
+		strings[id] = dictionary.GetInverse(int32(id))
+	}
+	return strings
+}
+
+// Writes the length of the data followed by the data
+func writeRecord(w io.Writer, data []byte) error {
+	var scratch [binary.MaxVarintLen64]byte
+	_, err := w.Write(scratch[:binary.PutUvarint(scratch[:], uint64(len(data)))])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+type runReader struct {
+	file   *os.File
+	reader *bufio.Reader
+	run    int
+
+	// the current list
+	termID int32
+	list   []byte
+}
+
+func openRun(name string, run int) (*runReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open run: %s", err)
+	}
+	return &runReader{file: f, reader: bufio.NewReaderSize(f, 1<<16), run: run}, nil
+}
+
+// Reads the next list, it returns false at the end of the run
+func (r *runReader) next() (bool, error) {
+	termID, err := binary.ReadUvarint(r.reader)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err == nil {
+		r.list, err = readRecord(r.reader)
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to read run %s: %s", r.file.Name(), err)
+	}
+
+	r.termID = int32(termID)
+	return true, nil
+}
+
+// The readers by their current term, readers of earlier runs first
+type runHeap []*runReader
+
+func (h runHeap) Len() int { return len(h) }
+func (h runHeap) Less(i, j int) bool {
+	if h[i].termID != h[j].termID {
+		return h[i].termID < h[j].termID
+	}
+	return h[i].run < h[j].run
+}
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*runReader)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,94 @@
+package spimi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitterfly/search/diskindex"
+	"github.com/bitterfly/search/indices"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeRandomDocuments(count int, length int) []*indices.InfoAndTerms {
+	random := rand.New(rand.NewSource(7))
+	zipf := rand.NewZipf(random, 1.1, 2, 2000)
+
+	docs := make([]*indices.InfoAndTerms, count)
+	for i := range docs {
+		docs[i] = indices.NewInfoAndTerms()
+		docs[i].Name = fmt.Sprintf("doc%d", i)
+		docs[i].Classes = []string{fmt.Sprintf("class%d", i%3)}
+		for j := 1 + random.Intn(length); j > 0; j-- {
+			docs[i].AddTerm(fmt.Sprintf("w%d", zipf.Uint64()))
+		}
+	}
+	return docs
+}
+
+func TestBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "spimi_test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	docs := makeRandomDocuments(300, 50)
+
+	expected := indices.NewTotalIndex()
+	for _, doc := range docs {
+		expected.Add(doc)
+	}
+	expected.Finalise()
+
+	filename := filepath.Join(dir, "index.idx")
+	builder, err := NewBuilder(filename, Options{MemoryBudget: 32 << 10, TempDir: dir})
+	assert.Nil(err)
+	builder.Metadata.Source = "test"
+
+	for i, doc := range docs {
+		docID, err := builder.Add(doc)
+		assert.Nil(err)
+		assert.Equal(int32(i), docID)
+	}
+	_, err = builder.Add(indices.NewInfoAndTerms())
+	assert.NotNil(err)
+
+	assert.Nil(builder.Close())
+	assert.True(len(builder.runs) > 5)
+
+	// only the index is left
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(err)
+	assert.Len(files, 1)
+
+	index, err := diskindex.Open(filename)
+	assert.Nil(err)
+	defer index.Close()
+
+	ti, err := index.Load()
+	assert.Nil(err)
+	assert.Nil(ti.Forward.Decompress())
+	assert.Nil(ti.Inverse.Decompress())
+	assert.Empty(ti.Verify())
+
+	assert.Equal(expected.Dictionary.Size, ti.Dictionary.Size)
+	for termID := int32(0); termID < expected.Dictionary.Size; termID++ {
+		assert.Equal(expected.Dictionary.GetInverse(termID), ti.Dictionary.GetInverse(termID))
+		assert.Equal(expected.TermPostings(termID), ti.TermPostings(termID))
+	}
+
+	assert.Equal(expected.Documents, ti.Documents)
+	for docID := range expected.Documents {
+		assert.Equal(expected.DocumentPostings(int32(docID)), ti.DocumentPostings(int32(docID)))
+	}
+
+	assert.Equal("test", ti.Metadata.Source)
+	assert.Equal(300, ti.Metadata.Documents)
+	assert.Equal(indices.FormatVersion, ti.Metadata.FormatVersion)
+	assert.NotEmpty(ti.Metadata.CodeVersion)
+	assert.False(ti.Metadata.Created.IsZero())
+}
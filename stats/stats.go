@@ -0,0 +1,299 @@
+// Package stats describes the collection in an index: its size, the distributions of terms,
+// classes and clusters, how well the vocabulary follows Zipf's and Heaps' laws
+// and roughly how much memory each part of the index takes.
+package stats
+
+import (
+	"math"
+	"sort"
+	"unsafe"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/bitterfly/search/trie"
+)
+
+type Stats struct {
+	Metadata indices.Metadata `json:"metadata"`
+
+	// Deleted documents aren't counted anywhere else
+	Documents        int `json:"documents"`
+	DeletedDocuments int `json:"deleted_documents"`
+	Terms            int `json:"terms"`
+
+	TotalLength         int64   `json:"total_length"`
+	AverageLength       float64 `json:"average_length"`
+	AverageUniqueLength float64 `json:"average_unique_length"`
+
+	// Postings in the inverse index, which is the same as in the forward one
+	Postings        int     `json:"postings"`
+	AveragePostings float64 `json:"average_postings"`
+	// Terms which occur only once in the collection
+	HapaxLegomena int `json:"hapax_legomena"`
+
+	Classes  []ClassCount `json:"classes"`
+	Clusters []int        `json:"clusters"`
+
+	TopByDocumentFrequency   []TermCount `json:"top_by_document_frequency"`
+	TopByCollectionFrequency []TermCount `json:"top_by_collection_frequency"`
+
+	Zipf  PowerLaw `json:"zipf"`
+	Heaps PowerLaw `json:"heaps"`
+
+	Memory []MemoryUsage `json:"memory"`
+}
+
+type ClassCount struct {
+	Name      string `json:"name"`
+	Documents int    `json:"documents"`
+}
+
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+// PowerLaw is y = Constant * x^Exponent fitted with least squares on the logarithms,
+// R2 is the coefficient of determination of the fit.
+// For Zipf's law x is the rank of a term by collection frequency and y its frequency,
+// for Heaps' law x is the number of terms in the collection so far and y the vocabulary size.
+type PowerLaw struct {
+	Constant float64 `json:"constant"`
+	Exponent float64 `json:"exponent"`
+	R2       float64 `json:"r2"`
+}
+
+// MemoryUsage is an estimate of the bytes taken by a part of the index.
+// Maps are counted as twice the size of their entries
+type MemoryUsage struct {
+	Structure string `json:"structure"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Compute goes over the whole index once, top is the number of terms in each top list
+func Compute(ti *indices.TotalIndex, top int) *Stats {
+	s := &Stats{
+		Metadata:         ti.Metadata,
+		Documents:        ti.Len(),
+		DeletedDocuments: ti.NumDeleted,
+	}
+
+	documentFrequencies := make([]int, len(ti.Inverse.PostingLists))
+	collectionFrequencies := make([]int, len(ti.Inverse.PostingLists))
+	for termID := range ti.Inverse.PostingLists {
+		ti.LoopOverTermPostings(int32(termID), func(posting *indices.Posting) {
+			documentFrequencies[termID]++
+			collectionFrequencies[termID] += int(posting.Count)
+		})
+
+		if documentFrequencies[termID] == 0 {
+			continue
+		}
+		s.Terms++
+		s.Postings += documentFrequencies[termID]
+		if collectionFrequencies[termID] == 1 {
+			s.HapaxLegomena++
+		}
+	}
+	if s.Terms != 0 {
+		s.AveragePostings = float64(s.Postings) / float64(s.Terms)
+	}
+
+	classCounts := make([]int, ti.ClassNames.Size)
+	s.Clusters = make([]int, len(ti.Centroids))
+	uniqueLength := int64(0)
+	for _, doc := range ti.Documents {
+		if doc.Deleted {
+			continue
+		}
+
+		s.TotalLength += int64(doc.Length)
+		uniqueLength += int64(doc.UniqueLength)
+		for _, class := range doc.Classes {
+			classCounts[class]++
+		}
+		if doc.ClusterID >= 0 && doc.ClusterID < len(s.Clusters) {
+			s.Clusters[doc.ClusterID]++
+		}
+	}
+	if s.Documents != 0 {
+		s.AverageLength = float64(s.TotalLength) / float64(s.Documents)
+		s.AverageUniqueLength = float64(uniqueLength) / float64(s.Documents)
+	}
+
+	for classID, count := range classCounts {
+		s.Classes = append(s.Classes, ClassCount{string(ti.ClassNames.GetInverse(int32(classID))), count})
+	}
+	sort.SliceStable(s.Classes, func(i, j int) bool { return s.Classes[i].Documents > s.Classes[j].Documents })
+
+	s.TopByDocumentFrequency = topTerms(ti, documentFrequencies, top)
+	s.TopByCollectionFrequency = topTerms(ti, collectionFrequencies, top)
+
+	s.Zipf = fitZipf(collectionFrequencies)
+	s.Heaps = fitHeaps(ti)
+	s.Memory = memoryUsage(ti)
+
+	return s
+}
+
+func topTerms(ti *indices.TotalIndex, counts []int, top int) []TermCount {
+	termIDs := make([]int32, 0, len(counts))
+	for termID, count := range counts {
+		if count != 0 {
+			termIDs = append(termIDs, int32(termID))
+		}
+	}
+	sort.SliceStable(termIDs, func(i, j int) bool { return counts[termIDs[i]] > counts[termIDs[j]] })
+
+	if len(termIDs) > top {
+		termIDs = termIDs[:top]
+	}
+
+	terms := make([]TermCount, len(termIDs))
+	for i, termID := range termIDs {
+		terms[i] = TermCount{string(ti.Dictionary.GetInverse(termID)), counts[termID]}
+	}
+	return terms
+}
+
+func fitZipf(collectionFrequencies []int) PowerLaw {
+	var frequencies []int
+	for _, frequency := range collectionFrequencies {
+		if frequency != 0 {
+			frequencies = append(frequencies, frequency)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(frequencies)))
+
+	xs := make([]float64, len(frequencies))
+	ys := make([]float64, len(frequencies))
+	for rank, frequency := range frequencies {
+		xs[rank] = float64(rank + 1)
+		ys[rank] = float64(frequency)
+	}
+	return fitPowerLaw(xs, ys)
+}
+
+// The vocabulary size is sampled after every document, in order of document id
+func fitHeaps(ti *indices.TotalIndex) PowerLaw {
+	seen := make([]bool, len(ti.Inverse.PostingLists))
+	vocabulary := 0
+	length := 0
+
+	var xs, ys []float64
+	for docID, doc := range ti.Documents {
+		if doc.Deleted {
+			continue
+		}
+
+		ti.LoopOverDocumentPostings(int32(docID), func(posting *indices.Posting) {
+			length += int(posting.Count)
+			if !seen[posting.Index] {
+				seen[posting.Index] = true
+				vocabulary++
+			}
+		})
+
+		xs = append(xs, float64(length))
+		ys = append(ys, float64(vocabulary))
+	}
+	return fitPowerLaw(xs, ys)
+}
+
+// Least squares on log y = log Constant + Exponent log x
+func fitPowerLaw(xs, ys []float64) PowerLaw {
+	if len(xs) < 2 {
+		return PowerLaw{}
+	}
+
+	n := float64(len(xs))
+	var sumX, sumY, sumXX, sumXY float64
+	for i := range xs {
+		x, y := math.Log(xs[i]), math.Log(ys[i])
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return PowerLaw{}
+	}
+	exponent := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - exponent*sumX) / n
+
+	var residual, total float64
+	mean := sumY / n
+	for i := range xs {
+		y := math.Log(ys[i])
+		predicted := intercept + exponent*math.Log(xs[i])
+		residual += (y - predicted) * (y - predicted)
+		total += (y - mean) * (y - mean)
+	}
+
+	r2 := 1.0
+	if total != 0 {
+		r2 = 1 - residual/total
+	}
+
+	return PowerLaw{Constant: math.Exp(intercept), Exponent: exponent, R2: r2}
+}
+
+func memoryUsage(ti *indices.TotalIndex) []MemoryUsage {
+	documents := int64(len(ti.Documents)) * int64(unsafe.Sizeof(indices.DocumentInfo{}))
+	for _, doc := range ti.Documents {
+		documents += int64(len(doc.Name)) + 4*int64(len(doc.Classes))
+	}
+
+	centroids := int64(0)
+	for _, centroid := range ti.Centroids {
+		centroids += 8 * int64(len(centroid))
+	}
+
+	maxScores := int64(0)
+	for name, bounds := range ti.MaxScores {
+		maxScores += int64(len(name)) + 8*int64(len(bounds))
+	}
+
+	return []MemoryUsage{
+		{"forward index", indexSize(&ti.Forward)},
+		{"inverse index", indexSize(&ti.Inverse)},
+		{"documents", documents},
+		{"dictionary", dictionarySize(&ti.Dictionary)},
+		{"class names", dictionarySize(&ti.ClassNames)},
+		{"centroids", centroids},
+		{"score bounds", maxScores},
+	}
+}
+
+func indexSize(index *indices.Index) int64 {
+	size := int64(len(index.PostingLists)) * int64(unsafe.Sizeof(indices.PostingList{}))
+	size += int64(len(index.Postings)) * int64(unsafe.Sizeof(indices.Posting{}))
+	for p := range index.Postings {
+		size += 4 * int64(len(index.Postings[p].Positions))
+	}
+	for _, list := range index.Encoded {
+		size += int64(unsafe.Sizeof(list)) + int64(len(list))
+	}
+	return size
+}
+
+func dictionarySize(dictionary *trie.BiDictionary) int64 {
+	t := &dictionary.Trie
+
+	size := 2 * int64(len(t.Transitions)) * int64(unsafe.Sizeof(trie.Transition{})+4)
+	size += 2 * int64(len(t.Values)) * 8
+	size += 2 * int64(len(t.Children)) * int64(4+unsafe.Sizeof([]trie.Transition{}))
+	for _, children := range t.Children {
+		size += int64(len(children)) * int64(unsafe.Sizeof(trie.Transition{}))
+	}
+	if dictionary.Frozen != nil {
+		size += dictionary.Frozen.Bytes()
+	}
+
+	size += 2 * int64(len(dictionary.Inverse)) * int64(4+unsafe.Sizeof([]byte{}))
+	for _, word := range dictionary.Inverse {
+		size += int64(len(word))
+	}
+	return size
+}
@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bitterfly/search/indices"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeDocument(text string, classes ...string) *indices.InfoAndTerms {
+	doc := indices.NewInfoAndTerms()
+	for _, term := range strings.Fields(text) {
+		doc.AddTerm(term)
+	}
+	doc.Classes = classes
+	return doc
+}
+
+func TestCompute(t *testing.T) {
+	assert := assert.New(t)
+
+	ti := indices.NewTotalIndex()
+	ti.Add(makeDocument("foo bar foo", "sports"))
+	ti.Add(makeDocument("bar qux", "sports", "politics"))
+	ti.Add(makeDocument("foo foo foo baz", "politics"))
+	ti.Add(makeDocument("bar"))
+	ti.Finalise()
+	ti.Centroids = [][]float64{{0}, {0}}
+	ti.Documents[0].ClusterID = 1
+	ti.Documents[1].ClusterID = 1
+	assert.Nil(ti.Delete(3))
+
+	s := Compute(ti, 2)
+
+	assert.Equal(3, s.Documents)
+	assert.Equal(1, s.DeletedDocuments)
+	assert.Equal(4, s.Terms)
+	assert.Equal(int64(9), s.TotalLength)
+	assert.Equal(3.0, s.AverageLength)
+	assert.Equal(2.0, s.AverageUniqueLength)
+	assert.Equal(6, s.Postings)
+	assert.Equal(1.5, s.AveragePostings)
+	assert.Equal(2, s.HapaxLegomena)
+
+	assert.Equal([]ClassCount{{"sports", 2}, {"politics", 2}}, s.Classes)
+	assert.Equal([]int{0, 2}, s.Clusters)
+
+	assert.Equal([]TermCount{{"foo", 2}, {"bar", 2}}, s.TopByDocumentFrequency)
+	assert.Equal([]TermCount{{"foo", 5}, {"bar", 2}}, s.TopByCollectionFrequency)
+
+	assert.Len(s.Memory, 7)
+	assert.True(s.Memory[1].Bytes > 0)
+
+	var table bytes.Buffer
+	assert.Nil(s.WriteTable(&table, 10))
+	assert.Contains(table.String(), "politics")
+}
+
+func TestFitPowerLaw(t *testing.T) {
+	assert := assert.New(t)
+
+	var xs, ys []float64
+	for x := 1.0; x <= 100; x++ {
+		xs = append(xs, x)
+		ys = append(ys, 3*math.Pow(x, -1.5))
+	}
+
+	fit := fitPowerLaw(xs, ys)
+	assert.InDelta(3, fit.Constant, 1e-9)
+	assert.InDelta(-1.5, fit.Exponent, 1e-9)
+	assert.InDelta(1, fit.R2, 1e-9)
+
+	assert.Equal(PowerLaw{}, fitPowerLaw(xs[:1], ys[:1]))
+}
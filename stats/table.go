@@ -0,0 +1,63 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// WriteTable writes the statistics in a human readable form, at most top classes are listed
+func (s *Stats) WriteTable(w io.Writer, top int) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "metadata\t%s\n", s.Metadata)
+	fmt.Fprintf(tw, "documents\t%d\n", s.Documents)
+	fmt.Fprintf(tw, "deleted documents\t%d\n", s.DeletedDocuments)
+	fmt.Fprintf(tw, "terms\t%d\n", s.Terms)
+	fmt.Fprintf(tw, "terms occurring once\t%d\n", s.HapaxLegomena)
+	fmt.Fprintf(tw, "total length\t%d\n", s.TotalLength)
+	fmt.Fprintf(tw, "average length\t%.2f\n", s.AverageLength)
+	fmt.Fprintf(tw, "average unique length\t%.2f\n", s.AverageUniqueLength)
+	fmt.Fprintf(tw, "postings\t%d\n", s.Postings)
+	fmt.Fprintf(tw, "average postings per term\t%.2f\n", s.AveragePostings)
+	fmt.Fprintf(tw, "zipf\tf = %.1f * rank^%.3f (r2 %.3f)\n", s.Zipf.Constant, s.Zipf.Exponent, s.Zipf.R2)
+	fmt.Fprintf(tw, "heaps\tv = %.2f * n^%.3f (r2 %.3f)\n", s.Heaps.Constant, s.Heaps.Exponent, s.Heaps.R2)
+
+	fmt.Fprintf(tw, "\nmemory\tbytes\n")
+	total := int64(0)
+	for _, usage := range s.Memory {
+		fmt.Fprintf(tw, "  %s\t%d\n", usage.Structure, usage.Bytes)
+		total += usage.Bytes
+	}
+	fmt.Fprintf(tw, "  total\t%d\n", total)
+
+	fmt.Fprintf(tw, "\nclass\tdocuments\n")
+	for i, class := range s.Classes {
+		if i == top {
+			fmt.Fprintf(tw, "  ... %d more\t\n", len(s.Classes)-top)
+			break
+		}
+		fmt.Fprintf(tw, "  %s\t%d\n", class.Name, class.Documents)
+	}
+
+	if len(s.Clusters) != 0 {
+		fmt.Fprintf(tw, "\ncluster\tdocuments\n")
+		for cluster, size := range s.Clusters {
+			fmt.Fprintf(tw, "  %d\t%d\n", cluster, size)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nterm by document frequency\tdocuments\tterm by collection frequency\toccurrences\n")
+	for i := 0; i < len(s.TopByDocumentFrequency) || i < len(s.TopByCollectionFrequency); i++ {
+		var byDocuments, byCollection TermCount
+		if i < len(s.TopByDocumentFrequency) {
+			byDocuments = s.TopByDocumentFrequency[i]
+		}
+		if i < len(s.TopByCollectionFrequency) {
+			byCollection = s.TopByCollectionFrequency[i]
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%s\t%d\n", byDocuments.Term, byDocuments.Count, byCollection.Term, byCollection.Count)
+	}
+
+	return tw.Flush()
+}
@@ -3,6 +3,9 @@ package trie
 type Dictionary struct {
 	Trie Trie
 	Size int32
+
+	// Set by Freeze, the words are then only in it and Trie is empty
+	Frozen *DoubleArray
 }
 
 type BiDictionary struct {
@@ -18,7 +21,16 @@ func NewDictionary() *Dictionary {
 	}
 }
 
+// Get returns the id of the word, adding it with the next id if it's new.
+// It's a write even on a frozen dictionary, which a new word thaws, so readers should use Find
 func (d *Dictionary) Get(word []byte) int32 {
+	if d.Frozen != nil {
+		if id := d.Frozen.Get(word); id != nil {
+			return *id
+		}
+		d.Thaw()
+	}
+
 	id := d.Trie.GetOrPut(word, d.Size)
 	if id == d.Size {
 		d.Size += 1
@@ -27,6 +39,68 @@ func (d *Dictionary) Get(word []byte) int32 {
 	return id
 }
 
+// Returns the id of the word or -1 if it's not in the dictionary
+// unlike Get it never adds the word, so it's safe for concurrent readers
+func (d *Dictionary) Find(word []byte) int32 {
+	var id *int32
+	if d.Frozen != nil {
+		id = d.Frozen.Get(word)
+	} else {
+		id = d.Trie.Get(word)
+	}
+	if id == nil {
+		return -1
+	}
+
+	return *id
+}
+
+// Freeze moves the words to a DoubleArray, once no more words are expected.
+// Get still adds words, but it has to thaw the dictionary first
+func (d *Dictionary) Freeze() {
+	if d.Frozen != nil {
+		return
+	}
+	d.Frozen = d.Trie.Freeze()
+	d.Trie = *New()
+}
+
+// Thaw moves the words back to a Trie, so that more can be added
+func (d *Dictionary) Thaw() {
+	if d.Frozen == nil {
+		return
+	}
+	d.Trie = *d.Frozen.Thaw()
+	d.Frozen = nil
+}
+
+func (d *Dictionary) trie() navigator {
+	if d.Frozen != nil {
+		return d.Frozen
+	}
+	return &d.Trie
+}
+
+// Calls operation for every word and its id, see Trie.Walk
+func (d *Dictionary) Walk(operation func([]byte, int32)) {
+	walk(d.trie(), operation)
+}
+
+// See Trie.WalkPrefix
+func (d *Dictionary) WalkPrefix(prefix []byte, operation func([]byte, int32)) {
+	walkPrefix(d.trie(), prefix, operation)
+}
+
+// See Trie.WalkPattern
+func (d *Dictionary) WalkPattern(pattern []byte, operation func([]byte, int32)) {
+	walkPattern(d.trie(), pattern, operation)
+}
+
+// See Trie.WalkFuzzy
+func (d *Dictionary) WalkFuzzy(word []byte, maxDistance int, operation func([]byte, int32, int)) {
+	walkFuzzy(d.trie(), word, maxDistance, operation)
+}
+
 func NewBiDictionary() *BiDictionary {
 	return &BiDictionary{
 		Dictionary: *NewDictionary(),
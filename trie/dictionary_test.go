@@ -23,3 +23,21 @@ func TestDictionary(t *testing.T) {
 		seen[id] = words[i]
 	}
 }
+
+func TestDictionary_Find(t *testing.T) {
+	dic := NewDictionary()
+	dic.Get([]byte("foo"))
+	dic.Get([]byte("bar"))
+
+	if id := dic.Find([]byte("bar")); id != 1 {
+		t.Errorf("id of bar should be 1 but is %d", id)
+	}
+
+	if id := dic.Find([]byte("ba")); id != -1 {
+		t.Errorf("ba is not in the dictionary but got id %d", id)
+	}
+
+	if dic.Size != 2 {
+		t.Errorf("Find shouldn't change the size of the dictionary, but it is %d", dic.Size)
+	}
+}
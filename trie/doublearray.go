@@ -0,0 +1,229 @@
+package trie
+
+// DoubleArray is a frozen Trie packed in a few flat arrays, which take a fraction of the memory
+// of the maps in Trie and don't have to be scanned by the garbage collector.
+// It can't be changed, Thaw makes a Trie out of it again
+type DoubleArray struct {
+	// The child of node s with label c is at Base[s] + c, if Check of it is s.
+	// Check is -1 for free slots
+	Base  []int32
+	Check []int32
+	// Label plus one of the first child of a node and of the next child of its parent after it,
+	// 0 if there's none. The children are in the order they were added to the Trie
+	First []uint16
+	Next  []uint16
+
+	Values []int32
+	// Bit set of the nodes with values
+	Final []uint64
+	Words int
+
+	// Where to start looking for free slots while building, the ones before it are mostly taken
+	firstFree int32
+}
+
+// Freeze packs the trie in a DoubleArray with the same words, values and walk order
+func (t *Trie) Freeze() *DoubleArray {
+	d := &DoubleArray{}
+	d.grow(1)
+	// the root is never the child of anything, but the slot is taken
+	d.Check[0] = 0
+	d.firstFree = 1
+
+	type pending struct {
+		from, to int32
+	}
+	queue := []pending{{0, 0}}
+	for len(queue) != 0 {
+		p := queue[0]
+		queue = queue[1:]
+
+		if value, ok := t.Values[p.from]; ok {
+			d.setValue(p.to, value)
+		}
+
+		transitions := t.Children[p.from]
+		if len(transitions) == 0 {
+			continue
+		}
+
+		base := d.findBase(transitions)
+		d.Base[p.to] = base
+		previous := int32(-1)
+		for _, transition := range transitions {
+			child := base + int32(transition.Label)
+			d.Check[child] = p.to
+			if previous == -1 {
+				d.First[p.to] = uint16(transition.Label) + 1
+			} else {
+				d.Next[previous] = uint16(transition.Label) + 1
+			}
+			previous = child
+			queue = append(queue, pending{transition.Id, child})
+		}
+	}
+
+	d.trim()
+	return d
+}
+
+func (d *DoubleArray) grow(size int) {
+	for len(d.Check) < size {
+		d.Base = append(d.Base, 0)
+		d.Check = append(d.Check, -1)
+		d.First = append(d.First, 0)
+		d.Next = append(d.Next, 0)
+		d.Values = append(d.Values, 0)
+	}
+}
+
+// Returns the smallest base at which all the children fit in free slots
+func (d *DoubleArray) findBase(transitions []Transition) int32 {
+	smallest := transitions[0].Label
+	for _, transition := range transitions {
+		if transition.Label < smallest {
+			smallest = transition.Label
+		}
+	}
+
+	for d.firstFree < int32(len(d.Check)) && d.Check[d.firstFree] != -1 {
+		d.firstFree++
+	}
+
+	// The bases have to be positive
+	start := d.firstFree
+	if start <= int32(smallest) {
+		start = int32(smallest) + 1
+	}
+
+	taken := 0
+	for position := start; ; position++ {
+		d.grow(int(position) + 256)
+		if d.Check[position] != -1 {
+			taken++
+			continue
+		}
+
+		base := position - int32(smallest)
+		fits := true
+		for _, transition := range transitions {
+			if d.Check[base+int32(transition.Label)] != -1 {
+				fits = false
+				break
+			}
+		}
+		if !fits {
+			continue
+		}
+
+		// The free slots left behind are too few to look at them every time
+		if float64(taken) >= 0.95*float64(position-start+1) {
+			d.firstFree = position
+		}
+		return base
+	}
+}
+
+func (d *DoubleArray) setValue(node int32, value int32) {
+	for len(d.Final) <= int(node)/64 {
+		d.Final = append(d.Final, 0)
+	}
+	d.Final[node/64] |= 1 << uint(node%64)
+	d.Values[node] = value
+	d.Words++
+}
+
+// Drops the free slots at the end and the spare capacity
+func (d *DoubleArray) trim() {
+	size := len(d.Check)
+	for size > 1 && d.Check[size-1] == -1 {
+		size--
+	}
+
+	d.Base = append([]int32(nil), d.Base[:size]...)
+	d.Check = append([]int32(nil), d.Check[:size]...)
+	d.First = append([]uint16(nil), d.First[:size]...)
+	d.Next = append([]uint16(nil), d.Next[:size]...)
+	d.Values = append([]int32(nil), d.Values[:size]...)
+	d.Final = append([]uint64(nil), d.Final...)
+}
+
+// Thaw makes a Trie with the same words, values and walk order, which can be changed
+func (d *DoubleArray) Thaw() *Trie {
+	t := New()
+	d.Walk(func(word []byte, value int32) {
+		t.Put(word, value)
+	})
+	return t
+}
+
+// Len returns the number of words
+func (d *DoubleArray) Len() int {
+	return d.Words
+}
+
+// Bytes returns the size of the arrays
+func (d *DoubleArray) Bytes() int64 {
+	return 4*int64(len(d.Base)+len(d.Check)+len(d.Values)) + 2*int64(len(d.First)+len(d.Next)) + 8*int64(len(d.Final))
+}
+
+func (d *DoubleArray) Get(word []byte) *int32 {
+	node, ok := find(d, word)
+	if !ok {
+		return nil
+	}
+
+	value, ok := d.value(node)
+	if !ok {
+		return nil
+	}
+	return &value
+}
+
+// Implementation of navigator
+func (d *DoubleArray) child(node int32, label byte) (int32, bool) {
+	base := d.Base[node]
+	if base < 1 {
+		return 0, false
+	}
+
+	child := base + int32(label)
+	if child >= int32(len(d.Check)) || d.Check[child] != node {
+		return 0, false
+	}
+	return child, true
+}
+
+func (d *DoubleArray) children(node int32, operation func(label byte, child int32)) {
+	for label := d.First[node]; label != 0; {
+		child := d.Base[node] + int32(label-1)
+		operation(byte(label-1), child)
+		label = d.Next[child]
+	}
+}
+
+func (d *DoubleArray) value(node int32) (int32, bool) {
+	if int(node)/64 >= len(d.Final) || d.Final[node/64]&(1<<uint(node%64)) == 0 {
+		return 0, false
+	}
+	return d.Values[node], true
+}
+
+func (d *DoubleArray) Walk(operation func([]byte, int32)) {
+	walk(d, operation)
+}
+
+// See Trie.WalkPrefix
+func (d *DoubleArray) WalkPrefix(prefix []byte, operation func([]byte, int32)) {
+	walkPrefix(d, prefix, operation)
+}
+
+// See Trie.WalkPattern
+func (d *DoubleArray) WalkPattern(pattern []byte, operation func([]byte, int32)) {
+	walkPattern(d, pattern, operation)
+}
+
+// See Trie.WalkFuzzy
+func (d *DoubleArray) WalkFuzzy(word []byte, maxDistance int, operation func([]byte, int32, int)) {
+	walkFuzzy(d, word, maxDistance, operation)
+}
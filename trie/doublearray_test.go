@@ -0,0 +1,146 @@
+package trie
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func makeRandomTrie(size int) (*Trie, [][]byte) {
+	random := rand.New(rand.NewSource(42))
+	trie := New()
+	words := make([][]byte, size)
+	for i := range words {
+		words[i] = make([]byte, 1+random.Intn(12))
+		for j := range words[i] {
+			// a small alphabet, so that the words share prefixes, with labels above 127 too
+			words[i][j] = "abcdeé"[random.Intn(7)]
+		}
+		trie.Put(words[i], int32(i))
+	}
+	return trie, words
+}
+
+func TestDoubleArray(t *testing.T) {
+	assert := assert.New(t)
+
+	trie, words := makeRandomTrie(2000)
+	frozen := trie.Freeze()
+
+	for _, word := range append(words, []byte("x"), []byte("abcdeabcdeabcde"), nil) {
+		assert.Equal(trie.Get(word), frozen.Get(word), "%s", word)
+	}
+	assert.Equal(len(trie.Values), frozen.Len())
+
+	// the same words in the same order
+	assert.Equal(collect(trie.Walk), collect(frozen.Walk))
+	assert.Equal(collect(trie.Walk), collect(frozen.Thaw().Walk))
+
+	empty := New().Freeze()
+	assert.Nil(empty.Get(nil))
+	assert.Nil(empty.Get([]byte("a")))
+	assert.Empty(collect(empty.Walk))
+}
+
+func TestDoubleArray_Walks(t *testing.T) {
+	assert := assert.New(t)
+	trie := makeWalkTrie()
+	frozen := trie.Freeze()
+
+	for _, prefix := range []string{"oil", "o", "bank", "oix", ""} {
+		assert.Equal(
+			collect(func(operation func([]byte, int32)) { trie.WalkPrefix([]byte(prefix), operation) }),
+			collect(func(operation func([]byte, int32)) { frozen.WalkPrefix([]byte(prefix), operation) }),
+		)
+	}
+
+	for _, pattern := range []string{"oil*", "b?nk", "*s*", "??", "**", "x*"} {
+		assert.Equal(
+			collect(func(operation func([]byte, int32)) { trie.WalkPattern([]byte(pattern), operation) }),
+			collect(func(operation func([]byte, int32)) { frozen.WalkPattern([]byte(pattern), operation) }),
+		)
+	}
+
+	matches := make(map[string]int)
+	frozen.WalkFuzzy([]byte("bank"), 1, func(match []byte, value int32, distance int) {
+		matches[string(match)] = distance
+	})
+	assert.Equal(map[string]int{"bank": 0, "bonk": 1, "banks": 1}, matches)
+}
+
+func TestDictionary_Freeze(t *testing.T) {
+	assert := assert.New(t)
+
+	dic := NewBiDictionary()
+	dic.Get([]byte("foo"))
+	dic.Get([]byte("bar"))
+	dic.Freeze()
+
+	assert.Equal(int32(1), dic.Find([]byte("bar")))
+	assert.Equal(int32(-1), dic.Find([]byte("baz")))
+	assert.Equal(int32(0), dic.Get([]byte("foo")))
+	assert.NotNil(dic.Frozen)
+
+	// a new word thaws it
+	assert.Equal(int32(2), dic.Get([]byte("baz")))
+	assert.Nil(dic.Frozen)
+	assert.Equal(int32(1), dic.Find([]byte("bar")))
+	assert.Equal([]byte("baz"), dic.GetInverse(2))
+	assert.Equal([]string{"foo", "bar", "baz"}, collect(dic.Walk))
+}
+
+func benchmarkFrozenGet(numWords int, b *testing.B) {
+	words := makeBenchmarkWords(numWords)
+
+	trie := New()
+	for i := int32(0); i < int32(numWords); i++ {
+		trie.Put(words[i], i)
+	}
+	frozen := trie.Freeze()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for j := 0; j < b.N; j++ {
+		for i := int32(0); i < int32(numWords); i++ {
+			frozen.Get(words[i])
+		}
+	}
+}
+
+func BenchmarkFrozenGet10(b *testing.B)    { benchmarkFrozenGet(10, b) }
+func BenchmarkFrozenGet100(b *testing.B)   { benchmarkFrozenGet(100, b) }
+func BenchmarkFrozenGet1000(b *testing.B)  { benchmarkFrozenGet(1000, b) }
+func BenchmarkFrozenGet10000(b *testing.B) { benchmarkFrozenGet(10000, b) }
+
+func heapSize() uint64 {
+	var stats runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// Reports the heap taken by a vocabulary of words sharing prefixes in both tries
+func BenchmarkMemory(b *testing.B) {
+	words := 50000
+	var trie *Trie
+	var frozen *DoubleArray
+
+	for j := 0; j < b.N; j++ {
+		before := heapSize()
+		trie, _ = makeRandomTrie(words)
+		trieSize := heapSize() - before
+
+		before = heapSize()
+		frozen = trie.Freeze()
+		frozenSize := heapSize() - before
+
+		b.ReportMetric(float64(trieSize)/float64(words), "trie-bytes/word")
+		b.ReportMetric(float64(frozenSize)/float64(words), "frozen-bytes/word")
+	}
+
+	runtime.KeepAlive(trie)
+	runtime.KeepAlive(frozen)
+}
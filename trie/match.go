@@ -0,0 +1,50 @@
+package trie
+
+// MatchPattern returns whether the word matches the pattern like in Trie.WalkPattern,
+// for dictionaries which aren't tries
+func MatchPattern(pattern []byte, word []byte) bool {
+	// matched[j] is whether the pattern so far matches the first j bytes of the word
+	matched := make([]bool, len(word)+1)
+	next := make([]bool, len(word)+1)
+	matched[0] = true
+
+	for _, symbol := range pattern {
+		for j := range next {
+			switch symbol {
+			case '*':
+				next[j] = matched[j] || (j > 0 && next[j-1])
+			case '?':
+				next[j] = j > 0 && matched[j-1]
+			default:
+				next[j] = j > 0 && matched[j-1] && word[j-1] == symbol
+			}
+		}
+		matched, next = next, matched
+	}
+
+	return matched[len(word)]
+}
+
+// EditDistance returns the Levenshtein distance between the words like in Trie.WalkFuzzy,
+// for dictionaries which aren't tries
+func EditDistance(a []byte, b []byte) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	next := make([]int, len(b)+1)
+	for i := range a {
+		next[0] = i + 1
+		for j := 1; j < len(next); j++ {
+			substitution := row[j-1]
+			if a[i] != b[j-1] {
+				substitution += 1
+			}
+			next[j] = minInt(substitution, minInt(row[j]+1, next[j-1]+1))
+		}
+		row, next = next, row
+	}
+
+	return row[len(b)]
+}
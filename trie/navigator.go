@@ -0,0 +1,143 @@
+package trie
+
+// navigator is what the walks need from a trie, so that they work both on
+// the mutable Trie and on the frozen DoubleArray. The root is always node 0
+type navigator interface {
+	child(node int32, label byte) (int32, bool)
+	// In the order the children were added
+	children(node int32, operation func(label byte, child int32))
+	value(node int32) (int32, bool)
+}
+
+// Returns the node of the word if there's a path for it
+func find(n navigator, word []byte) (int32, bool) {
+	node := int32(0)
+	for _, letter := range word {
+		destination, ok := n.child(node, letter)
+		if !ok {
+			return 0, false
+		}
+		node = destination
+	}
+	return node, true
+}
+
+func walk(n navigator, operation func([]byte, int32)) {
+	var word []byte
+	walkNode(n, 0, &word, operation)
+}
+
+func walkNode(n navigator, node int32, word *[]byte, operation func([]byte, int32)) {
+	//If final apply operation
+	if value, ok := n.value(node); ok {
+		operation(*word, value)
+	}
+
+	n.children(node, func(label byte, child int32) {
+		*word = append(*word, label)
+		walkNode(n, child, word, operation)
+		*word = (*word)[:len(*word)-1]
+	})
+}
+
+func walkPrefix(n navigator, prefix []byte, operation func([]byte, int32)) {
+	node, ok := find(n, prefix)
+	if !ok {
+		return
+	}
+
+	word := append([]byte(nil), prefix...)
+	walkNode(n, node, &word, operation)
+}
+
+// Since every node corresponds to exactly one word, reaching the same node
+// at the same place in the pattern would only repeat work (and matches)
+type patternState struct {
+	node     int32
+	position int
+}
+
+func walkPattern(n navigator, pattern []byte, operation func([]byte, int32)) {
+	var word []byte
+	visited := make(map[patternState]struct{})
+	walkPatternNode(n, 0, pattern, 0, &word, visited, operation)
+}
+
+func walkPatternNode(n navigator, node int32, pattern []byte, position int, word *[]byte, visited map[patternState]struct{}, operation func([]byte, int32)) {
+	state := patternState{node: node, position: position}
+	if _, ok := visited[state]; ok {
+		return
+	}
+	visited[state] = struct{}{}
+
+	if position == len(pattern) {
+		if value, ok := n.value(node); ok {
+			operation(*word, value)
+		}
+		return
+	}
+
+	switch pattern[position] {
+	case '*':
+		walkPatternNode(n, node, pattern, position+1, word, visited, operation)
+		n.children(node, func(label byte, child int32) {
+			*word = append(*word, label)
+			walkPatternNode(n, child, pattern, position, word, visited, operation)
+			*word = (*word)[:len(*word)-1]
+		})
+	case '?':
+		n.children(node, func(label byte, child int32) {
+			*word = append(*word, label)
+			walkPatternNode(n, child, pattern, position+1, word, visited, operation)
+			*word = (*word)[:len(*word)-1]
+		})
+	default:
+		if destination, ok := n.child(node, pattern[position]); ok {
+			*word = append(*word, pattern[position])
+			walkPatternNode(n, destination, pattern, position+1, word, visited, operation)
+			*word = (*word)[:len(*word)-1]
+		}
+	}
+}
+
+func walkFuzzy(n navigator, word []byte, maxDistance int, operation func([]byte, int32, int)) {
+	row := make([]int, len(word)+1)
+	for i := range row {
+		row[i] = i
+	}
+
+	if value, ok := n.value(0); ok && row[len(word)] <= maxDistance {
+		operation(nil, value, row[len(word)])
+	}
+
+	var prefix []byte
+	walkFuzzyNode(n, 0, word, row, maxDistance, &prefix, operation)
+}
+
+func walkFuzzyNode(n navigator, node int32, word []byte, row []int, maxDistance int, prefix *[]byte, operation func([]byte, int32, int)) {
+	n.children(node, func(label byte, child int32) {
+		next := make([]int, len(row))
+		next[0] = row[0] + 1
+		best := next[0]
+
+		for i := 1; i < len(row); i++ {
+			substitution := row[i-1]
+			if word[i-1] != label {
+				substitution += 1
+			}
+			next[i] = minInt(substitution, minInt(row[i]+1, next[i-1]+1))
+			best = minInt(best, next[i])
+		}
+
+		if best > maxDistance {
+			return
+		}
+
+		*prefix = append(*prefix, label)
+		if value, ok := n.value(child); ok && next[len(word)] <= maxDistance {
+			operation(*prefix, value, next[len(word)])
+		}
+		walkFuzzyNode(n, child, word, next, maxDistance, prefix, operation)
+		*prefix = (*prefix)[:len(*prefix)-1]
+	})
+}
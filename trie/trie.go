@@ -95,23 +95,48 @@ func (t *Trie) PutLambda(word []byte, lambda func(int32) int32, defaultValue int
 	}
 }
 
-func (t *Trie) walk(node int32, word *[]byte, operation func([]byte, int32)) {
-	value, ok := t.Values[node]
-
-	//If final apply operation
-	if ok {
-		operation(*word, value)
-	}
+// Implementation of navigator
+func (t *Trie) child(node int32, label byte) (int32, bool) {
+	destination, ok := t.Transitions[Transition{Id: node, Label: label}]
+	return destination, ok
+}
 
+func (t *Trie) children(node int32, operation func(label byte, child int32)) {
 	for _, transition := range t.Children[node] {
-		*word = append(*word, transition.Label)
-		t.walk(transition.Id, word, operation)
-		*word = (*word)[:len(*word)-1]
+		operation(transition.Label, transition.Id)
 	}
+}
 
+func (t *Trie) value(node int32) (int32, bool) {
+	value, ok := t.Values[node]
+	return value, ok
 }
 
 func (t *Trie) Walk(operation func([]byte, int32)) {
-	var word []byte
-	t.walk(0, &word, operation)
+	walk(t, operation)
+}
+
+// Calls operation for every word in the trie which starts with prefix
+func (t *Trie) WalkPrefix(prefix []byte, operation func([]byte, int32)) {
+	walkPrefix(t, prefix, operation)
+}
+
+// Calls operation for every word in the trie which matches the pattern,
+// where '*' matches any (possibly empty) sequence of bytes and '?' matches exactly one byte
+func (t *Trie) WalkPattern(pattern []byte, operation func([]byte, int32)) {
+	walkPattern(t, pattern, operation)
+}
+
+// Calls operation for every word in the trie whose Levenshtein distance to word
+// is at most maxDistance. Every node keeps the row of the edit distance table
+// between its prefix and word, so subtrees which can't get close enough are skipped
+func (t *Trie) WalkFuzzy(word []byte, maxDistance int, operation func([]byte, int32, int)) {
+	walkFuzzy(t, word, maxDistance, operation)
+}
+
+func minInt(i, j int) int {
+	if i < j {
+		return i
+	}
+	return j
 }
@@ -135,6 +135,77 @@ func TestTrie_Walk(t *testing.T) {
 	assert.ElementsMatch(values, []int32{44, 41, 43, 42})
 }
 
+func makeWalkTrie() *Trie {
+	trie := New()
+	for i, word := range []string{"oil", "oils", "oilseed", "bank", "bonk", "banks", "brink", "oi"} {
+		trie.Put([]byte(word), int32(i))
+	}
+	return trie
+}
+
+func collect(walk func(func([]byte, int32))) []string {
+	var words []string
+	walk(func(word []byte, value int32) {
+		words = append(words, string(word))
+	})
+	return words
+}
+
+func TestTrie_WalkPrefix(t *testing.T) {
+	assert := assert.New(t)
+	trie := makeWalkTrie()
+
+	walkPrefix := func(prefix string) []string {
+		return collect(func(operation func([]byte, int32)) { trie.WalkPrefix([]byte(prefix), operation) })
+	}
+
+	assert.ElementsMatch([]string{"oil", "oils", "oilseed"}, walkPrefix("oil"))
+	assert.ElementsMatch([]string{"oi", "oil", "oils", "oilseed"}, walkPrefix("o"))
+	assert.ElementsMatch([]string{"bank", "banks"}, walkPrefix("bank"))
+	assert.Empty(walkPrefix("oix"))
+	assert.Len(walkPrefix(""), 8)
+}
+
+func TestTrie_WalkPattern(t *testing.T) {
+	assert := assert.New(t)
+	trie := makeWalkTrie()
+
+	walkPattern := func(pattern string) []string {
+		return collect(func(operation func([]byte, int32)) { trie.WalkPattern([]byte(pattern), operation) })
+	}
+
+	assert.ElementsMatch([]string{"oil", "oils", "oilseed"}, walkPattern("oil*"))
+	assert.ElementsMatch([]string{"bank", "bonk"}, walkPattern("b?nk"))
+	assert.ElementsMatch([]string{"bank", "bonk", "brink"}, walkPattern("b*nk"))
+	assert.ElementsMatch([]string{"oils", "banks"}, walkPattern("*s"))
+	assert.ElementsMatch([]string{"oils", "oilseed", "banks"}, walkPattern("*s*"))
+	assert.ElementsMatch([]string{"oil"}, walkPattern("oil"))
+	assert.ElementsMatch([]string{"oi"}, walkPattern("??"))
+	assert.Len(walkPattern("**"), 8)
+	assert.Empty(walkPattern("x*"))
+}
+
+func TestTrie_WalkFuzzy(t *testing.T) {
+	assert := assert.New(t)
+	trie := makeWalkTrie()
+
+	walkFuzzy := func(word string, maxDistance int) map[string]int {
+		matches := make(map[string]int)
+		trie.WalkFuzzy([]byte(word), maxDistance, func(match []byte, value int32, distance int) {
+			matches[string(match)] = distance
+		})
+		return matches
+	}
+
+	assert.Equal(map[string]int{"bank": 0}, walkFuzzy("bank", 0))
+	assert.Equal(map[string]int{"bank": 0, "bonk": 1, "banks": 1}, walkFuzzy("bank", 1))
+	// no transpositions, so swapped letters cost two edits
+	assert.Equal(map[string]int{"bank": 2, "bonk": 2}, walkFuzzy("bnak", 2))
+	assert.Equal(map[string]int{"oi": 1, "oil": 0, "oils": 1}, walkFuzzy("oil", 1))
+	assert.Equal(map[string]int{"oi": 1, "oil": 2}, walkFuzzy("o", 2))
+	assert.Empty(walkFuzzy("xyz", 1))
+}
+
 func makeBenchmarkWords(size int) [][]byte {
 	randomStrings := make([][]byte, size)
 
@@ -192,3 +263,30 @@ func BenchmarkGet10(b *testing.B)    { benchmarkGet(10, b) }
 func BenchmarkGet100(b *testing.B)   { benchmarkGet(100, b) }
 func BenchmarkGet1000(b *testing.B)  { benchmarkGet(1000, b) }
 func BenchmarkGet10000(b *testing.B) { benchmarkGet(10000, b) }
+
+func TestMatchPattern(t *testing.T) {
+	assert := assert.New(t)
+	trie := makeWalkTrie()
+
+	// the same words as walking the trie
+	for _, pattern := range []string{"oil*", "b?nk", "b*nk", "*s", "*s*", "oil", "??", "**", "x*", ""} {
+		var matched []string
+		trie.Walk(func(word []byte, value int32) {
+			if MatchPattern([]byte(pattern), word) {
+				matched = append(matched, string(word))
+			}
+		})
+		walked := collect(func(operation func([]byte, int32)) { trie.WalkPattern([]byte(pattern), operation) })
+		assert.ElementsMatch(walked, matched, "pattern %q", pattern)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0, EditDistance([]byte("bank"), []byte("bank")))
+	assert.Equal(1, EditDistance([]byte("bank"), []byte("banks")))
+	assert.Equal(2, EditDistance([]byte("bnak"), []byte("bank")))
+	assert.Equal(3, EditDistance(nil, []byte("oil")))
+	assert.Equal(3, EditDistance([]byte("kitten"), []byte("sitting")))
+}